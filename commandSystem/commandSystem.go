@@ -84,7 +84,14 @@ func handleStatus(args []string) {
 	fmt.Printf("%-20s %d\n", "CPU:", numCPU)
 	fmt.Printf("%-20s %v\n", "Uptime:", upTime)
 	fmt.Printf("%-20s %d\n", "Number of connected users:", numUsers)
-	fmt.Printf("%-20s %d\n", "Number of tokens issued:", len(httpService.Tokens))
+	fmt.Printf("%-20s %d\n", "Number of tokens issued:", httpService.TokenCount())
+
+	// 发送队列指标
+	queueStats := websocketService.GetQueueStats()
+	fmt.Printf("%-20s %d\n", "Queued messages:", queueStats.TotalDepth)
+	fmt.Printf("%-20s %d\n", "Max queue depth:", queueStats.MaxDepth)
+	fmt.Printf("%-20s %d\n", "Dropped messages:", queueStats.DroppedMessages)
+	fmt.Printf("%-20s %d\n", "Slow consumers kicked:", queueStats.DisconnectedConsumer)
 }
 
 func handleInvalidateToken(args []string) {
@@ -94,8 +101,7 @@ func handleInvalidateToken(args []string) {
 	}
 
 	token := args[0]
-	if _, ok := httpService.Tokens[token]; ok {
-		delete(httpService.Tokens, token)
+	if httpService.InvalidateToken(token) {
 		fmt.Println("Token invalidated:", token)
 	} else {
 		fmt.Println("Token not found:", token)
@@ -128,10 +134,13 @@ func handleUserInfo(args []string) {
 	fmt.Printf("用户备注: %s\n", user.UserNote)
 	fmt.Printf("用户权限: %d\n", user.UserPermission)
 	fmt.Printf("用户好友列表: %s\n", string(user.UserFriendList))
+	for _, session := range websocketService.GetSessions(userID) {
+		fmt.Printf("设备: %s, 平台: %s, 连接时间: %s, 发送队列长度: %d\n", session.DeviceID, session.Platform, session.ConnectTime.Format(time.DateTime), session.QueueDepth())
+	}
 }
 func handleKickUser(args []string) {
-	if len(args) != 1 {
-		fmt.Println("Usage: kickuser [userID]")
+	if len(args) != 1 && len(args) != 2 {
+		fmt.Println("Usage: kick [userID] [deviceID(可选)]")
 		return
 	}
 
@@ -141,22 +150,24 @@ func handleKickUser(args []string) {
 		return
 	}
 
-	websocketService.ClientsLock.Lock()
-	user, ok := websocketService.Clients[userID]
-	websocketService.ClientsLock.Unlock()
-
-	if !ok {
+	sessions := websocketService.GetSessions(userID)
+	if len(sessions) == 0 {
 		fmt.Println("User not found:", userID)
 		return
 	}
 
-	err = user.Conn.Close()
-	if err != nil {
-		fmt.Println("Failed to disconnect user:", err)
-		return
+	kicked := 0
+	for _, session := range sessions {
+		if len(args) == 2 && session.DeviceID != args[1] {
+			continue
+		}
+		session.Close()
+		kicked++
+		fmt.Println("User disconnected:", userID, "device:", session.DeviceID)
+	}
+	if kicked == 0 {
+		fmt.Println("Device not found:", args[1])
 	}
-
-	fmt.Println("User disconnected:", userID)
 }
 func handleListUsers(args []string) {
 	websocketService.ClientsLock.Lock()
@@ -164,7 +175,10 @@ func handleListUsers(args []string) {
 
 	logger.Info("当前在线用户:")
 	for id, user := range websocketService.Clients {
-		fmt.Printf("用户ID: %d, 用户名: %s\n", id, user.UserName)
+		fmt.Printf("用户ID: %d, 用户名: %s, 在线设备数: %d\n", id, user.UserName, len(user.Sessions))
+		for deviceID, session := range user.Sessions {
+			fmt.Printf("    设备: %s, 平台: %s, 连接时间: %s\n", deviceID, session.Platform, session.ConnectTime.Format(time.DateTime))
+		}
 	}
 }
 func handleBroadcast(args []string) {
@@ -205,11 +219,7 @@ func handleBanUser(args []string) {
 	}
 	websocketService.ClientsLock.Unlock()
 
-	token, ok := httpService.UserToTokens[userID]
-	if ok {
-		tokenUser, _ := httpService.Tokens[*token]
-		tokenUser.UserPermission = config.PermissionBannedUser
-	}
+	httpService.SetTokenPermission(userID, config.PermissionBannedUser)
 	fmt.Println("User banned:", userID)
 }
 func handleUnbanUser(args []string) {
@@ -238,20 +248,13 @@ func handleUnbanUser(args []string) {
 	}
 	websocketService.ClientsLock.Unlock()
 
-	token, ok := httpService.UserToTokens[userID]
-	if ok {
-		tokenUser, _ := httpService.Tokens[*token]
-		tokenUser.UserPermission = config.PermissionOrdinaryUser
-	}
+	httpService.SetTokenPermission(userID, config.PermissionOrdinaryUser)
 
 	fmt.Println("User unbanned:", userID)
 }
 func handleListTokens(args []string) {
 	fmt.Println("当前有效的tokens:")
-	for token, user := range httpService.Tokens {
-		// 检查token是否已经过期
-		if httpService.CheckTokenExpiry(token) {
-			fmt.Printf("Token: %s, 用户ID: %d, 权限等级: %d\n", token, user.UserId, user.UserPermission)
-		}
+	for token, user := range httpService.ValidTokens() {
+		fmt.Printf("Token: %s, 用户ID: %d, 权限等级: %d\n", token, user.UserId, user.UserPermission)
 	}
 }
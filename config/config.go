@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	jsonprovider "jsonProvider"
 	"logger"
 	"os"
 )
@@ -26,14 +27,25 @@ type Config struct {
 	}
 	WebsocketConnBufferSize          int      `json:"websocketConnBufferSize"`
 	WebSocketHeartbeatTimeoutSeconds int      `json:"webSocketHeartbeatTimeoutSeconds"`
+	WebSocketSendQueueSize           int      `json:"webSocketSendQueueSize"`
+	WebSocketSendQueueOverflowPolicy string   `json:"webSocketSendQueueOverflowPolicy"` // dropOldest 或 disconnect
+	WebSocketLoginTimeoutSeconds     int      `json:"webSocketLoginTimeoutSeconds"`
+	WebSocketLoginMaxAttempts        int      `json:"webSocketLoginMaxAttempts"`
 	SaltLength                       int      `json:"saltLength"`
 	TokenLength                      int      `json:"tokenLength"`
 	AuthorizedServerTokens           []string `json:"authorizedServerTokens"`
 	TokenExpiryHours                 float64  `json:"tokenExpiryHours"`
+	MessageAckTimeoutSeconds         int      `json:"messageAckTimeoutSeconds"`   // 首次重发未确认消息前的等待时间，之后按指数退避
+	MessageAckDeadlineSeconds        int      `json:"messageAckDeadlineSeconds"`  // 超过该时间仍未确认的消息不再重发，转为离线消息
+	SyncMaxPageSize                  int      `json:"syncMaxPageSize"`            // sync 命令单页最多返回的消息数
+	FriendRequestExpireHours         int      `json:"friendRequestExpireHours"`   // 超过该时间未处理的好友申请自动过期
+	DirectMessagePolicy              string   `json:"directMessagePolicy"`        // 私聊消息策略：open、friends 或 friendsAndGroupMembers，用户未单独设置时使用
+	MessageRecallWindowSeconds       int      `json:"messageRecallWindowSeconds"` // 发送者可以撤回消息的时限
+	MessageEditWindowSeconds         int      `json:"messageEditWindowSeconds"`   // 发送者可以编辑消息的时限
+	SignalMinIntervalMillis          int      `json:"signalMinIntervalMillis"`    // 同一用户发送信号的平均最小间隔，可以连续发送少量信号，超出频率的信号被丢弃
 	UserSettings                     struct {
-		DefaultAvatar   string `json:"defaultAvatar"`
-		DefaultSettings struct {
-		}
+		DefaultAvatar       string `json:"defaultAvatar"`
+		DefaultSettings     jsonprovider.UserSettings
 		DefaultPermission   int      `json:"defaultPermission"`
 		DefaultFriendList   []string `json:"defaultFriendList"`
 		DefaultGroupList    []string `json:"defaultGroupList"`
@@ -60,6 +72,47 @@ type Config struct {
 		ChangeSettings       string `json:"changeSettings"`
 		ChangeAvatar         string `json:"changeAvatar"`
 		Logout               string `json:"logout"`
+		AckMessage           string `json:"ackMessage"`
+		Sync                 string `json:"sync"`
+		InviteGroupMember    string `json:"inviteGroupMember"`
+		JoinGroup            string `json:"joinGroup"`
+		LeaveGroup           string `json:"leaveGroup"`
+		KickGroupMember      string `json:"kickGroupMember"`
+		TransferGroup        string `json:"transferGroup"`
+		GroupEvent           string `json:"groupEvent"`
+		SetGroupMemberRole   string `json:"setGroupMemberRole"`
+		MuteGroupMember      string `json:"muteGroupMember"`
+		GetGroupMessages     string `json:"getGroupMessages"`
+		GetFriendRequests    string `json:"getFriendRequests"`
+		AcceptFriendRequest  string `json:"acceptFriendRequest"`
+		RejectFriendRequest  string `json:"rejectFriendRequest"`
+		CancelFriendRequest  string `json:"cancelFriendRequest"`
+		FriendRequestEvent   string `json:"friendRequestEvent"`
+		BlockUser            string `json:"blockUser"`
+		UnblockUser          string `json:"unblockUser"`
+		GetBlockList         string `json:"getBlockList"`
+		RecallMessage        string `json:"recallMessage"`
+		MessageRecallEvent   string `json:"messageRecallEvent"`
+		EditMessage          string `json:"editMessage"`
+		MessageEditEvent     string `json:"messageEditEvent"`
+		GetMessageEdits      string `json:"getMessageEdits"`
+		GetThreadMessages    string `json:"getThreadMessages"`
+		AddReaction          string `json:"addReaction"`
+		RemoveReaction       string `json:"removeReaction"`
+		ReactionEvent        string `json:"reactionEvent"`
+		GetUnreadMentions    string `json:"getUnreadMentions"`
+		MarkMentionsRead     string `json:"markMentionsRead"`
+		MarkRead             string `json:"markRead"`
+		ReadReceiptEvent     string `json:"readReceiptEvent"`
+		GetConversations     string `json:"getConversations"`
+		SendSignal           string `json:"sendSignal"`
+		SignalEvent          string `json:"signalEvent"`
+		SetPresence          string `json:"setPresence"`
+		GetPresence          string `json:"getPresence"`
+		AcceptGroupInvite    string `json:"acceptGroupInvite"`
+		DeclineGroupInvite   string `json:"declineGroupInvite"`
+		GetGroupInvites      string `json:"getGroupInvites"`
+		GroupInviteEvent     string `json:"groupInviteEvent"`
 	}
 }
 
@@ -275,11 +328,22 @@ func getDefaultConfig() Config {
 		TokenExpiryHours:                 24.00,
 		WebsocketConnBufferSize:          2048,
 		WebSocketHeartbeatTimeoutSeconds: 10,
+		WebSocketSendQueueSize:           256,
+		WebSocketSendQueueOverflowPolicy: "dropOldest",
+		WebSocketLoginTimeoutSeconds:     30,
+		WebSocketLoginMaxAttempts:        3,
 		AuthorizedServerTokens:           []string{"token1", "token2", "token3"},
+		MessageAckTimeoutSeconds:         5,
+		MessageAckDeadlineSeconds:        60,
+		SyncMaxPageSize:                  200,
+		FriendRequestExpireHours:         168,
+		DirectMessagePolicy:              jsonprovider.DMPolicyOpen,
+		MessageRecallWindowSeconds:       120,
+		MessageEditWindowSeconds:         900,
+		SignalMinIntervalMillis:          300,
 		UserSettings: struct {
-			DefaultAvatar   string `json:"defaultAvatar"`
-			DefaultSettings struct {
-			}
+			DefaultAvatar       string `json:"defaultAvatar"`
+			DefaultSettings     jsonprovider.UserSettings
 			DefaultPermission   int      `json:"defaultPermission"`
 			DefaultFriendList   []string `json:"defaultFriendList"`
 			DefaultGroupList    []string `json:"defaultGroupList"`
@@ -287,10 +351,20 @@ func getDefaultConfig() Config {
 			DefaultHomePageData struct {
 			}
 		}{
-			DefaultNote:         "暂无签名",
-			DefaultPermission:   PermissionOrdinaryUser,
-			DefaultAvatar:       "http://127.0.0.1",
-			DefaultSettings:     struct{}{},
+			DefaultNote:       "暂无签名",
+			DefaultPermission: PermissionOrdinaryUser,
+			DefaultAvatar:     "http://127.0.0.1",
+			DefaultSettings: jsonprovider.UserSettings{
+				Notifications: jsonprovider.NotificationSettings{
+					DirectMessages: jsonprovider.NotifyAll,
+					GroupMessages:  jsonprovider.NotifyAll,
+					FriendRequests: jsonprovider.NotifyAll,
+				},
+				OnlineStatusVisibility: jsonprovider.VisibleToEveryone,
+				WhoCanAddMe:            jsonprovider.AddMeEveryone,
+				DirectMessagePolicy:    jsonprovider.DMPolicyDefault,
+				Language:               "zh-CN",
+			},
 			DefaultGroupList:    []string{"3", "4"},
 			DefaultFriendList:   []string{"1", "2"},
 			DefaultHomePageData: struct{}{},
@@ -314,6 +388,47 @@ func getDefaultConfig() Config {
 			ChangeSettings       string "json:\"changeSettings\""
 			ChangeAvatar         string "json:\"changeAvatar\""
 			Logout               string "json:\"logout\""
+			AckMessage           string "json:\"ackMessage\""
+			Sync                 string "json:\"sync\""
+			InviteGroupMember    string "json:\"inviteGroupMember\""
+			JoinGroup            string "json:\"joinGroup\""
+			LeaveGroup           string "json:\"leaveGroup\""
+			KickGroupMember      string "json:\"kickGroupMember\""
+			TransferGroup        string "json:\"transferGroup\""
+			GroupEvent           string "json:\"groupEvent\""
+			SetGroupMemberRole   string "json:\"setGroupMemberRole\""
+			MuteGroupMember      string "json:\"muteGroupMember\""
+			GetGroupMessages     string "json:\"getGroupMessages\""
+			GetFriendRequests    string "json:\"getFriendRequests\""
+			AcceptFriendRequest  string "json:\"acceptFriendRequest\""
+			RejectFriendRequest  string "json:\"rejectFriendRequest\""
+			CancelFriendRequest  string "json:\"cancelFriendRequest\""
+			FriendRequestEvent   string "json:\"friendRequestEvent\""
+			BlockUser            string "json:\"blockUser\""
+			UnblockUser          string "json:\"unblockUser\""
+			GetBlockList         string "json:\"getBlockList\""
+			RecallMessage        string "json:\"recallMessage\""
+			MessageRecallEvent   string "json:\"messageRecallEvent\""
+			EditMessage          string "json:\"editMessage\""
+			MessageEditEvent     string "json:\"messageEditEvent\""
+			GetMessageEdits      string "json:\"getMessageEdits\""
+			GetThreadMessages    string "json:\"getThreadMessages\""
+			AddReaction          string "json:\"addReaction\""
+			RemoveReaction       string "json:\"removeReaction\""
+			ReactionEvent        string "json:\"reactionEvent\""
+			GetUnreadMentions    string "json:\"getUnreadMentions\""
+			MarkMentionsRead     string "json:\"markMentionsRead\""
+			MarkRead             string "json:\"markRead\""
+			ReadReceiptEvent     string "json:\"readReceiptEvent\""
+			GetConversations     string "json:\"getConversations\""
+			SendSignal           string "json:\"sendSignal\""
+			SignalEvent          string "json:\"signalEvent\""
+			SetPresence          string "json:\"setPresence\""
+			GetPresence          string "json:\"getPresence\""
+			AcceptGroupInvite    string "json:\"acceptGroupInvite\""
+			DeclineGroupInvite   string "json:\"declineGroupInvite\""
+			GetGroupInvites      string "json:\"getGroupInvites\""
+			GroupInviteEvent     string "json:\"groupInviteEvent\""
 		}{
 			Heart:                "heart",
 			CheckUserOnlineState: "checkUserOnlineState",
@@ -333,6 +448,47 @@ func getDefaultConfig() Config {
 			ChangeSettings:       "changeSettings",
 			ChangeAvatar:         "changeAvatar",
 			Logout:               "logout",
+			AckMessage:           "ackMessage",
+			Sync:                 "sync",
+			InviteGroupMember:    "inviteGroupMember",
+			JoinGroup:            "joinGroup",
+			LeaveGroup:           "leaveGroup",
+			KickGroupMember:      "kickGroupMember",
+			TransferGroup:        "transferGroup",
+			GroupEvent:           "groupEvent",
+			SetGroupMemberRole:   "setGroupMemberRole",
+			MuteGroupMember:      "muteGroupMember",
+			GetGroupMessages:     "getGroupMessages",
+			GetFriendRequests:    "getFriendRequests",
+			AcceptFriendRequest:  "acceptFriendRequest",
+			RejectFriendRequest:  "rejectFriendRequest",
+			CancelFriendRequest:  "cancelFriendRequest",
+			FriendRequestEvent:   "friendRequestEvent",
+			BlockUser:            "blockUser",
+			UnblockUser:          "unblockUser",
+			GetBlockList:         "getBlockList",
+			RecallMessage:        "recallMessage",
+			MessageRecallEvent:   "messageRecallEvent",
+			EditMessage:          "editMessage",
+			MessageEditEvent:     "messageEditEvent",
+			GetMessageEdits:      "getMessageEdits",
+			GetThreadMessages:    "getThreadMessages",
+			AddReaction:          "addReaction",
+			RemoveReaction:       "removeReaction",
+			ReactionEvent:        "reactionEvent",
+			GetUnreadMentions:    "getUnreadMentions",
+			MarkMentionsRead:     "markMentionsRead",
+			MarkRead:             "markRead",
+			ReadReceiptEvent:     "readReceiptEvent",
+			GetConversations:     "getConversations",
+			SendSignal:           "sendSignal",
+			SignalEvent:          "signalEvent",
+			SetPresence:          "setPresence",
+			GetPresence:          "getPresence",
+			AcceptGroupInvite:    "acceptGroupInvite",
+			DeclineGroupInvite:   "declineGroupInvite",
+			GetGroupInvites:      "getGroupInvites",
+			GroupInviteEvent:     "groupInviteEvent",
 		},
 	}
 
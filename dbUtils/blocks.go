@@ -0,0 +1,65 @@
+package dbUtils
+
+import (
+	"database/sql"
+	jsonprovider "jsonProvider"
+	"time"
+)
+
+// BlockUser 将blockedID加入userID的屏蔽列表并取消双方之间待处理的好友申请，重复屏蔽不报错
+func BlockUser(userID int, blockedID int) error {
+	now := time.Now().UnixNano()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	_, err = tx.Exec("INSERT IGNORE INTO userblocks (userID, blockedID, createTime) VALUES (?, ?, ?)", userID, blockedID, now)
+	if err != nil {
+		return err
+	}
+	// 双方之间待处理的好友申请全部取消，避免屏蔽后通过接受旧申请成为好友
+	_, err = tx.Exec("UPDATE friendrequests SET state = ?, updateTime = ? WHERE state = ? AND ((senderID = ? AND receiverID = ?) OR (senderID = ? AND receiverID = ?))",
+		jsonprovider.FriendRequestCancelled, now, jsonprovider.FriendRequestPending, userID, blockedID, blockedID, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UnblockUser 将blockedID移出userID的屏蔽列表
+func UnblockUser(userID int, blockedID int) error {
+	_, err := db.Exec("DELETE FROM userblocks WHERE userID = ? AND blockedID = ?", userID, blockedID)
+	return err
+}
+
+// IsBlocked 判断userID是否屏蔽了otherUserID
+func IsBlocked(userID int, otherUserID int) (bool, error) {
+	var blockedID int
+	err := db.QueryRow("SELECT blockedID FROM userblocks WHERE userID = ? AND blockedID = ?", userID, otherUserID).Scan(&blockedID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetBlockList 获取用户屏蔽的用户ID列表
+func GetBlockList(userID int) ([]int, error) {
+	rows, err := db.Query("SELECT blockedID FROM userblocks WHERE userID = ? ORDER BY createTime", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blockList := []int{}
+	for rows.Next() {
+		var blockedID int
+		err = rows.Scan(&blockedID)
+		if err != nil {
+			return nil, err
+		}
+		blockList = append(blockList, blockedID)
+	}
+	return blockList, rows.Err()
+}
@@ -29,6 +29,16 @@ func CheckTableExistence(db *sql.DB, DBname string, tableName string) int {
 	return tablecount
 }
 
+func CheckColumnExistence(db *sql.DB, DBname string, tableName string, columnName string) int {
+	query := "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = ? AND table_name = ? AND column_name = ?"
+	var columncount int
+	err := db.QueryRow(query, DBname, tableName, columnName).Scan(&columncount)
+	if err != nil {
+		logger.Error("Failed to check column existence:", err)
+	}
+	return columncount
+}
+
 var confData config.Config
 
 func GetDBPtr() *sql.DB {
@@ -67,6 +77,8 @@ func DbInit(confData config.Config) {
 			userGroupList json DEFAULT NULL,
 			userHomePageData json DEFAULT NULL,
 			userSettings json DEFAULT NULL,
+			lastSeen BIGINT unsigned NOT NULL DEFAULT 0,
+			presence varchar(16) NOT NULL DEFAULT '',
 			userPasswordHashValue text,
 			passwordSalt BINARY(` + strconv.Itoa(confData.SaltLength) + `),
 			PRIMARY KEY (userID)
@@ -89,6 +101,8 @@ func DbInit(confData config.Config) {
 				groupMaster int DEFAULT NULL,
 				groupMembers json DEFAULT NULL,
 				groupSettings json DEFAULT NULL,
+				groupRoles json DEFAULT NULL,
+				groupMutes json DEFAULT NULL,
 				PRIMARY KEY (groupID)
 			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 		`
@@ -97,74 +111,243 @@ func DbInit(confData config.Config) {
 			logger.Error("Failed to create table:", err)
 		}
 	}
-	if CheckTableExistence(db, _BasicChatDBName, "offlinemessages") == 0 {
+	if CheckTableExistence(db, _BasicChatDBName, "messages") == 0 {
 		UseDB(db, _BasicChatDBName)
-		logger.Warn("找不到离线消息数据表，自动创建")
-		createTable := `CREATE TABLE offlinemessages (
+		logger.Warn("找不到消息数据表，自动创建")
+		createTable := `CREATE TABLE messages (
     			messageID INT UNSIGNED NOT NULL AUTO_INCREMENT,
     			senderID int unsigned NOT NULL,
 				receiverID int unsigned NOT NULL,
 				time BIGINT unsigned DEFAULT NULL,
 				messageBody text DEFAULT NULL,
 				messageType smallint unsigned DEFAULT NULL,
+				state int unsigned DEFAULT 0,
+				groupID int unsigned NOT NULL DEFAULT 0,
+				recallTime BIGINT unsigned NOT NULL DEFAULT 0,
+				editTime BIGINT unsigned NOT NULL DEFAULT 0,
+				replyTo INT UNSIGNED NOT NULL DEFAULT 0,
+				threadRoot INT UNSIGNED NOT NULL DEFAULT 0,
+				mentions json DEFAULT NULL,
+				mentionAll tinyint(1) NOT NULL DEFAULT 0,
+				kind varchar(16) NOT NULL DEFAULT 'text',
+				content json DEFAULT NULL,
 				PRIMARY KEY (messageID) USING BTREE,
-				KEY idx_senderID (senderID)
+				KEY idx_senderID (senderID),
+				KEY idx_receiverID (receiverID),
+				KEY idx_groupID (groupID),
+				KEY idx_threadRoot (threadRoot)
 			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
 		_, err := db.Exec(createTable)
 		if err != nil {
 			logger.Error("Failed to create table:", err)
 		}
 	}
-	if CheckTableExistence(db, _BasicChatDBName, "offlinegroupmessages") == 0 {
+	if CheckColumnExistence(db, _BasicChatDBName, "userdatatable", "lastSeen") == 0 {
 		UseDB(db, _BasicChatDBName)
-		logger.Warn("找不到离线群消息数据表，自动创建")
-		createTable := `CREATE TABLE offlinegroupmessages (
-    			messageID INT UNSIGNED NOT NULL AUTO_INCREMENT,
-    			senderID int unsigned NOT NULL,
-				receiverID int unsigned NOT NULL,
-				time BIGINT unsigned DEFAULT NULL,
-				messageBody text DEFAULT NULL,
-				messageType smallint unsigned DEFAULT NULL,
-				PRIMARY KEY (messageID) USING BTREE,
-				KEY idx_senderID (senderID)
+		logger.Warn("用户数据表缺少lastSeen字段，自动添加")
+		_, err := db.Exec("ALTER TABLE userdatatable ADD COLUMN lastSeen BIGINT unsigned NOT NULL DEFAULT 0")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+	}
+	if CheckColumnExistence(db, _BasicChatDBName, "userdatatable", "presence") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("用户数据表缺少presence字段，自动添加")
+		_, err := db.Exec("ALTER TABLE userdatatable ADD COLUMN presence varchar(16) NOT NULL DEFAULT ''")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+	}
+	// 旧版群聊表只记录群主，群成员角色与禁言状态需要补充字段
+	if CheckColumnExistence(db, _BasicChatDBName, "groupdatatable", "groupRoles") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("群聊数据表缺少groupRoles字段，自动添加")
+		_, err := db.Exec("ALTER TABLE groupdatatable ADD COLUMN groupRoles json DEFAULT NULL, ADD COLUMN groupMutes json DEFAULT NULL")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+	}
+	// 旧版消息表没有群ID字段，私聊与群聊消息统一保存后需要补充
+	if CheckColumnExistence(db, _BasicChatDBName, "messages", "groupID") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("消息数据表缺少groupID字段，自动添加")
+		_, err := db.Exec("ALTER TABLE messages ADD COLUMN groupID int unsigned NOT NULL DEFAULT 0, ADD KEY idx_receiverID (receiverID), ADD KEY idx_groupID (groupID)")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+	}
+	// 消息撤回后只做标记，recallTime 为0表示未撤回
+	if CheckColumnExistence(db, _BasicChatDBName, "messages", "recallTime") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("消息数据表缺少recallTime字段，自动添加")
+		_, err := db.Exec("ALTER TABLE messages ADD COLUMN recallTime BIGINT unsigned NOT NULL DEFAULT 0")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+	}
+	if CheckColumnExistence(db, _BasicChatDBName, "messages", "editTime") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("消息数据表缺少editTime字段，自动添加")
+		_, err := db.Exec("ALTER TABLE messages ADD COLUMN editTime BIGINT unsigned NOT NULL DEFAULT 0")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+	}
+	if CheckColumnExistence(db, _BasicChatDBName, "messages", "threadRoot") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("消息数据表缺少replyTo与threadRoot字段，自动添加")
+		_, err := db.Exec("ALTER TABLE messages ADD COLUMN replyTo INT UNSIGNED NOT NULL DEFAULT 0, ADD COLUMN threadRoot INT UNSIGNED NOT NULL DEFAULT 0, ADD KEY idx_threadRoot (threadRoot)")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+	}
+	if CheckColumnExistence(db, _BasicChatDBName, "messages", "mentions") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("消息数据表缺少mentions与mentionAll字段，自动添加")
+		_, err := db.Exec("ALTER TABLE messages ADD COLUMN mentions json DEFAULT NULL, ADD COLUMN mentionAll tinyint(1) NOT NULL DEFAULT 0")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+	}
+	if CheckColumnExistence(db, _BasicChatDBName, "messages", "kind") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("消息数据表缺少kind与content字段，自动添加")
+		_, err := db.Exec("ALTER TABLE messages ADD COLUMN kind varchar(16) NOT NULL DEFAULT 'text', ADD COLUMN content json DEFAULT NULL")
+		if err != nil {
+			logger.Error("Failed to alter table:", err)
+		}
+		// 旧版的系统消息与撤回、编辑通知使用 system 种类
+		_, err = db.Exec("UPDATE messages SET kind = 'system' WHERE messageType <> 0")
+		if err != nil {
+			logger.Error("Failed to update table:", err)
+		}
+	}
+	if CheckTableExistence(db, _BasicChatDBName, "messagedeliveries") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("找不到消息投递数据表，自动创建")
+		createTable := `CREATE TABLE messagedeliveries (
+				messageID INT UNSIGNED NOT NULL,
+				userID int unsigned NOT NULL,
+				state smallint unsigned NOT NULL DEFAULT 0,
+				updateTime BIGINT unsigned DEFAULT NULL,
+				PRIMARY KEY (messageID, userID),
+				KEY idx_userID_state (userID, state)
 			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
 		_, err := db.Exec(createTable)
 		if err != nil {
 			logger.Error("Failed to create table:", err)
 		}
 	}
-	if CheckTableExistence(db, _BasicChatDBName, "messages") == 0 {
+	if CheckTableExistence(db, _BasicChatDBName, "messageedits") == 0 {
 		UseDB(db, _BasicChatDBName)
-		logger.Warn("找不到消息数据表，自动创建")
-		createTable := `CREATE TABLE messages (
-    			messageID INT UNSIGNED NOT NULL AUTO_INCREMENT,
-    			senderID int unsigned NOT NULL,
-				receiverID int unsigned NOT NULL,
-				time BIGINT unsigned DEFAULT NULL,
+		logger.Warn("找不到消息编辑记录表，自动创建")
+		createTable := `CREATE TABLE messageedits (
+				editID INT UNSIGNED NOT NULL AUTO_INCREMENT,
+				messageID INT UNSIGNED NOT NULL,
 				messageBody text DEFAULT NULL,
-				messageType smallint unsigned DEFAULT NULL,
-				state int unsigned DEFAULT 0,
-				PRIMARY KEY (messageID) USING BTREE,
-				KEY idx_senderID (senderID)
+				editTime BIGINT unsigned DEFAULT NULL,
+				PRIMARY KEY (editID),
+				KEY idx_messageID (messageID)
 			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
 		_, err := db.Exec(createTable)
 		if err != nil {
 			logger.Error("Failed to create table:", err)
 		}
 	}
-	if CheckTableExistence(db, _BasicChatDBName, "groupmessagees") == 0 {
+	if CheckTableExistence(db, _BasicChatDBName, "messagereactions") == 0 {
 		UseDB(db, _BasicChatDBName)
-		logger.Warn("找不到群消息数据表，自动创建")
-		createTable := `CREATE TABLE groupmessagees (
-    			messageID INT UNSIGNED NOT NULL AUTO_INCREMENT,
-    			senderID int unsigned NOT NULL,
+		logger.Warn("找不到消息回应数据表，自动创建")
+		createTable := `CREATE TABLE messagereactions (
+				messageID INT UNSIGNED NOT NULL,
+				userID int unsigned NOT NULL,
+				emoji varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+				createTime BIGINT unsigned DEFAULT NULL,
+				PRIMARY KEY (messageID, userID, emoji)
+			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
+		_, err := db.Exec(createTable)
+		if err != nil {
+			logger.Error("Failed to create table:", err)
+		}
+	}
+	if CheckTableExistence(db, _BasicChatDBName, "messagementions") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("找不到@记录数据表，自动创建")
+		createTable := `CREATE TABLE messagementions (
+				messageID INT UNSIGNED NOT NULL,
+				userID int unsigned NOT NULL,
+				groupID int unsigned NOT NULL,
+				readTime BIGINT unsigned NOT NULL DEFAULT 0,
+				PRIMARY KEY (messageID, userID),
+				KEY idx_userID_readTime (userID, readTime)
+			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
+		_, err := db.Exec(createTable)
+		if err != nil {
+			logger.Error("Failed to create table:", err)
+		}
+	}
+	if CheckTableExistence(db, _BasicChatDBName, "syncpointers") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("找不到同步游标数据表，自动创建")
+		createTable := `CREATE TABLE syncpointers (
+				userID int unsigned NOT NULL,
+				deviceID varchar(64) NOT NULL,
+				lastMessageID INT UNSIGNED NOT NULL DEFAULT 0,
+				updateTime BIGINT unsigned DEFAULT NULL,
+				PRIMARY KEY (userID, deviceID)
+			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
+		_, err := db.Exec(createTable)
+		if err != nil {
+			logger.Error("Failed to create table:", err)
+		}
+	}
+	if CheckTableExistence(db, _BasicChatDBName, "friendrequests") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("找不到好友申请数据表，自动创建")
+		createTable := `CREATE TABLE friendrequests (
+				requestID INT UNSIGNED NOT NULL AUTO_INCREMENT,
+				senderID int unsigned NOT NULL,
 				receiverID int unsigned NOT NULL,
-				time BIGINT unsigned DEFAULT NULL,
-				messageBody text DEFAULT NULL,
-				messageType smallint unsigned DEFAULT NULL,
-				extradata text DEFAULT NULL,
-				PRIMARY KEY (messageID) USING BTREE,
-				KEY idx_senderID (senderID)
+				message varchar(255) DEFAULT NULL,
+				state smallint unsigned NOT NULL DEFAULT 0,
+				createTime BIGINT unsigned DEFAULT NULL,
+				updateTime BIGINT unsigned DEFAULT NULL,
+				PRIMARY KEY (requestID),
+				KEY idx_receiverID_state (receiverID, state),
+				KEY idx_senderID_state (senderID, state)
+			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
+		_, err := db.Exec(createTable)
+		if err != nil {
+			logger.Error("Failed to create table:", err)
+		}
+	}
+	if CheckTableExistence(db, _BasicChatDBName, "groupinvites") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("找不到入群邀请数据表，自动创建")
+		createTable := `CREATE TABLE groupinvites (
+				inviteID INT UNSIGNED NOT NULL AUTO_INCREMENT,
+				groupID int unsigned NOT NULL,
+				inviterID int unsigned NOT NULL,
+				inviteeID int unsigned NOT NULL,
+				state smallint unsigned NOT NULL DEFAULT 0,
+				createTime BIGINT unsigned DEFAULT NULL,
+				updateTime BIGINT unsigned DEFAULT NULL,
+				PRIMARY KEY (inviteID),
+				KEY idx_inviteeID_state (inviteeID, state),
+				KEY idx_groupID_inviteeID (groupID, inviteeID)
+			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
+		_, err := db.Exec(createTable)
+		if err != nil {
+			logger.Error("Failed to create table:", err)
+		}
+	}
+	if CheckTableExistence(db, _BasicChatDBName, "userblocks") == 0 {
+		UseDB(db, _BasicChatDBName)
+		logger.Warn("找不到屏蔽列表数据表，自动创建")
+		createTable := `CREATE TABLE userblocks (
+				userID int unsigned NOT NULL,
+				blockedID int unsigned NOT NULL,
+				createTime BIGINT unsigned DEFAULT NULL,
+				PRIMARY KEY (userID, blockedID)
 			  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`
 		_, err := db.Exec(createTable)
 		if err != nil {
@@ -188,5 +371,6 @@ func DbInit(confData config.Config) {
 			logger.Error("Failed to create table:", err)
 		}
 	}
+	migrateOfflineMessages()
 
 }
@@ -0,0 +1,267 @@
+package dbUtils
+
+import (
+	"database/sql"
+	"encoding/json"
+	jsonprovider "jsonProvider"
+	"time"
+)
+
+// CreateFriendRequest 保存一条待处理的好友申请，返回requestID
+func CreateFriendRequest(senderID int, receiverID int, message string) (int, error) {
+	now := time.Now().UnixNano()
+	result, err := db.Exec("INSERT INTO friendrequests (senderID, receiverID, message, state, createTime, updateTime) VALUES (?, ?, ?, ?, ?, ?)", senderID, receiverID, message, jsonprovider.FriendRequestPending, now, now)
+	if err != nil {
+		return 0, err
+	}
+	requestID, err := result.LastInsertId()
+	return int(requestID), err
+}
+
+// GetFriendRequest 获取好友申请，不存在时返回 sql.ErrNoRows
+func GetFriendRequest(requestID int) (*jsonprovider.FriendRequest, error) {
+	var request jsonprovider.FriendRequest
+	var message sql.NullString
+	err := db.QueryRow("SELECT requestID, senderID, receiverID, message, state, createTime, updateTime FROM friendrequests WHERE requestID = ?", requestID).Scan(&request.RequestID, &request.SenderID, &request.ReceiverID, &message, &request.State, &request.CreateTime, &request.UpdateTime)
+	if err != nil {
+		return nil, err
+	}
+	request.Message = message.String
+	return &request, nil
+}
+
+// FindPendingFriendRequest 查找senderID发给receiverID的、创建时间不早于notBefore的待处理申请，不存在时返回 sql.ErrNoRows
+func FindPendingFriendRequest(senderID int, receiverID int, notBefore int64) (*jsonprovider.FriendRequest, error) {
+	var requestID int
+	err := db.QueryRow("SELECT requestID FROM friendrequests WHERE senderID = ? AND receiverID = ? AND state = ? AND createTime >= ? ORDER BY requestID DESC LIMIT 1", senderID, receiverID, jsonprovider.FriendRequestPending, notBefore).Scan(&requestID)
+	if err != nil {
+		return nil, err
+	}
+	return GetFriendRequest(requestID)
+}
+
+// GetPendingFriendRequests 获取用户收到的和发出的、创建时间不早于notBefore的待处理申请
+func GetPendingFriendRequests(userID int, notBefore int64) (incoming []jsonprovider.FriendRequest, outgoing []jsonprovider.FriendRequest, err error) {
+	rows, err := db.Query("SELECT requestID, senderID, receiverID, message, state, createTime, updateTime FROM friendrequests WHERE (receiverID = ? OR senderID = ?) AND state = ? AND createTime >= ? ORDER BY requestID", userID, userID, jsonprovider.FriendRequestPending, notBefore)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	incoming = []jsonprovider.FriendRequest{}
+	outgoing = []jsonprovider.FriendRequest{}
+	for rows.Next() {
+		var request jsonprovider.FriendRequest
+		var message sql.NullString
+		err = rows.Scan(&request.RequestID, &request.SenderID, &request.ReceiverID, &message, &request.State, &request.CreateTime, &request.UpdateTime)
+		if err != nil {
+			return nil, nil, err
+		}
+		request.Message = message.String
+		if request.ReceiverID == userID {
+			incoming = append(incoming, request)
+		} else {
+			outgoing = append(outgoing, request)
+		}
+	}
+	return incoming, outgoing, rows.Err()
+}
+
+// ExpireFriendRequests 将创建时间早于before的待处理申请标记为已过期
+func ExpireFriendRequests(before int64) error {
+	_, err := db.Exec("UPDATE friendrequests SET state = ?, updateTime = ? WHERE state = ? AND createTime < ?", jsonprovider.FriendRequestExpired, time.Now().UnixNano(), jsonprovider.FriendRequestPending, before)
+	return err
+}
+
+// UpdateFriendRequestState 将待处理的申请改为state，申请已被处理时返回 sql.ErrNoRows
+func UpdateFriendRequestState(requestID int, state int) error {
+	result, err := db.Exec("UPDATE friendrequests SET state = ?, updateTime = ? WHERE requestID = ? AND state = ?", state, time.Now().UnixNano(), requestID, jsonprovider.FriendRequestPending)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AcceptFriendRequest 在事务中将待处理的申请标记为已接受，并将双方加入对方的好友列表，申请已被处理时返回 sql.ErrNoRows
+func AcceptFriendRequest(request *jsonprovider.FriendRequest) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	result, err := tx.Exec("UPDATE friendrequests SET state = ?, updateTime = ? WHERE requestID = ? AND state = ?", jsonprovider.FriendRequestAccepted, time.Now().UnixNano(), request.RequestID, jsonprovider.FriendRequestPending)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	addTime := int(time.Now().UnixNano())
+	err = modifyFriendList(tx, request.SenderID, func(friends jsonprovider.FriendList) (jsonprovider.FriendList, error) {
+		return addFriend(friends, request.ReceiverID, addTime), nil
+	})
+	if err != nil {
+		return err
+	}
+	err = modifyFriendList(tx, request.ReceiverID, func(friends jsonprovider.FriendList) (jsonprovider.FriendList, error) {
+		return addFriend(friends, request.SenderID, addTime), nil
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveFriendship 将双方从对方的好友列表中删除
+func RemoveFriendship(userID int, friendID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	err = modifyFriendList(tx, userID, func(friends jsonprovider.FriendList) (jsonprovider.FriendList, error) {
+		return removeFriend(friends, friendID), nil
+	})
+	if err != nil {
+		return err
+	}
+	err = modifyFriendList(tx, friendID, func(friends jsonprovider.FriendList) (jsonprovider.FriendList, error) {
+		return removeFriend(friends, userID), nil
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetFriendList 获取用户的好友列表
+func GetFriendList(userID int) (jsonprovider.FriendList, error) {
+	var friendList []byte
+	err := db.QueryRow("SELECT userFriendList FROM userdatatable WHERE userID = ?", userID).Scan(&friendList)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFriendList(friendList)
+}
+
+// UpdateFriendSettings 在事务中修改用户为某个好友设置的信息，对方不是好友时返回 sql.ErrNoRows
+func UpdateFriendSettings(userID int, friendID int, modify func(friend *jsonprovider.Friend) error) (*jsonprovider.Friend, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	var updated jsonprovider.Friend
+	err = modifyFriendList(tx, userID, func(friends jsonprovider.FriendList) (jsonprovider.FriendList, error) {
+		friend := friends.Find(friendID)
+		if friend == nil {
+			return nil, sql.ErrNoRows
+		}
+		err := modify(friend)
+		if err != nil {
+			return nil, err
+		}
+		updated = *friend
+		return friends, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, tx.Commit()
+}
+
+// ShareGroup 判断两个用户是否在同一个群聊中
+func ShareGroup(userID int, otherUserID int) (bool, error) {
+	var groupID int
+	err := db.QueryRow("SELECT groupID FROM groupdatatable WHERE JSON_CONTAINS(groupMembers, CAST(? AS JSON)) AND JSON_CONTAINS(groupMembers, CAST(? AS JSON)) LIMIT 1", userID, otherUserID).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ParseFriendList 解析好友列表，兼容旧版只保存用户ID的格式（包括以字符串保存的用户ID，如默认好友列表）
+func ParseFriendList(friendList []byte) (jsonprovider.FriendList, error) {
+	friends := jsonprovider.FriendList{}
+	if len(friendList) == 0 {
+		return friends, nil
+	}
+	var items []json.RawMessage
+	err := json.Unmarshal(friendList, &items)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		var friend jsonprovider.Friend
+		if len(item) > 0 && item[0] == '{' {
+			err = json.Unmarshal(item, &friend)
+		} else {
+			var id json.Number
+			err = json.Unmarshal(item, &id)
+			if err == nil {
+				var friendID int64
+				friendID, err = id.Int64()
+				friend.UserID = int(friendID)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		friends = append(friends, friend)
+	}
+	return friends, nil
+}
+
+func modifyFriendList(tx *sql.Tx, userID int, modify func(friends jsonprovider.FriendList) (jsonprovider.FriendList, error)) error {
+	var friendList []byte
+	err := tx.QueryRow("SELECT userFriendList FROM userdatatable WHERE userID = ? FOR UPDATE", userID).Scan(&friendList)
+	if err != nil {
+		return err
+	}
+	friends, err := ParseFriendList(friendList)
+	if err != nil {
+		return err
+	}
+	friends, err = modify(friends)
+	if err != nil {
+		return err
+	}
+	friendList, err = json.Marshal(friends)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE userdatatable SET userFriendList = ? WHERE userID = ?", friendList, userID)
+	return err
+}
+
+func addFriend(friends jsonprovider.FriendList, friendID int, addTime int) jsonprovider.FriendList {
+	if friends.Contains(friendID) {
+		return friends
+	}
+	return append(friends, jsonprovider.Friend{UserID: friendID, AddTime: addTime})
+}
+
+func removeFriend(friends jsonprovider.FriendList, friendID int) jsonprovider.FriendList {
+	result := make(jsonprovider.FriendList, 0, len(friends))
+	for _, friend := range friends {
+		if friend.UserID != friendID {
+			result = append(result, friend)
+		}
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package dbUtils
+
+import (
+	jsonprovider "jsonProvider"
+	"reflect"
+	"testing"
+)
+
+func TestParseFriendList(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want jsonprovider.FriendList
+	}{
+		{"空", ``, jsonprovider.FriendList{}},
+		{"空数组", `[]`, jsonprovider.FriendList{}},
+		{"旧版用户ID", `[2, 3]`, jsonprovider.FriendList{{UserID: 2}, {UserID: 3}}},
+		{"旧版字符串用户ID", `["1"]`, jsonprovider.FriendList{{UserID: 1}}},
+		{"新版", `[{"userId":2,"addTime":100,"remark":"小王","group":"同学","muted":true,"pinned":true}]`,
+			jsonprovider.FriendList{{UserID: 2, AddTime: 100, Remark: "小王", Group: "同学", Muted: true, Pinned: true}}},
+		{"新旧混合", `[2, {"userId":3,"remark":"老李"}]`, jsonprovider.FriendList{{UserID: 2}, {UserID: 3, Remark: "老李"}}},
+	}
+	for _, test := range tests {
+		got, err := ParseFriendList([]byte(test.list))
+		if err != nil {
+			t.Errorf("%s: 返回错误 %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: 得到 %+v，期望 %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseFriendListInvalid(t *testing.T) {
+	for _, list := range []string{`{"userId":2}`, `["abc"]`, `[1.5]`, `[true]`, `[`} {
+		if _, err := ParseFriendList([]byte(list)); err == nil {
+			t.Errorf("ParseFriendList(%s) 没有返回错误", list)
+		}
+	}
+}
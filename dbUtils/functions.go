@@ -26,42 +26,6 @@ func SaveUserToDB(username, hashedPassword string, salt []byte) (int64, error) {
 	return userID, nil
 }
 
-// SaveOfflineMessageToDB 返回messageID
-func SaveOfflineMessageToDB(userID int, recipientID int, messageContent string, messageType int) (int, error) {
-	insertQuery := "INSERT INTO offlinemessages (senderID,receiverID,messageBody,time,messageType) VALUES (?,?,?,?,?)"
-	timestamp := time.Now().UnixNano() //纳秒事件戳
-	result, err := db.Exec(insertQuery, userID, recipientID, messageContent, timestamp, messageType)
-	if err != nil {
-		logger.Error("保存用户离线消息时出现错误", err)
-		return 0, err
-	}
-
-	messageID, err := result.LastInsertId()
-	if err != nil {
-		logger.Error("获取插入消息的ID时出现错误", err)
-		return 0, err
-	}
-
-	return int(messageID), nil
-}
-func SaveOfflineGroupMessageToDB(userID int, recipientID int, messageContent string, messageType int) (int, error) {
-	insertQuery := "INSERT INTO offlinegroupmessages (senderID,receiverID,messageBody,time,messageType) VALUES (?,?,?,?,?)"
-	timestamp := time.Now().UnixNano() //纳秒事件戳
-	result, err := db.Exec(insertQuery, userID, recipientID, messageContent, timestamp, messageType)
-	if err != nil {
-		logger.Error("保存群聊离线消息时出现错误", err)
-		return 0, err
-	}
-
-	messageID, err := result.LastInsertId()
-	if err != nil {
-		logger.Error("获取群聊插入消息的ID时出现错误", err)
-		return 0, err
-	}
-
-	return int(messageID), nil
-}
-
 func GetDBPasswordHash(userID int) (string, []byte, error) {
 	UseDB(db, _BasicChatDBName)
 	query := "SELECT userPasswordHashValue, passwordSalt FROM userdatatable WHERE userID = ?"
@@ -91,6 +55,16 @@ func GetUserFromDB(userID int) (*jsonprovider.GetUserDataResponse, error) {
 		logger.Error("获取用户数据失败:", err)
 		return nil, err
 	}
+	// 旧版好友列表只保存用户ID，统一转换为带有好友信息的格式
+	friends, err := ParseFriendList(userFriendList)
+	if err != nil {
+		logger.Error("解析好友列表失败:", err)
+		return nil, err
+	}
+	userFriendList, err = json.Marshal(friends)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建 User 结构体
 	user := &jsonprovider.GetUserDataResponse{
@@ -186,3 +160,56 @@ func GetPostsFromDB(startTime, endTime int64) ([]jsonprovider.GetPostResponse, e
 
 	return posts, nil
 }
+
+// SaveUserSettings 保存用户设置
+func SaveUserSettings(userID int, settings jsonprovider.UserSettings) error {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE userdatatable SET userSettings = ? WHERE userID = ?", settingsJSON, userID)
+	return err
+}
+
+// GetUserSettings 获取数据库中保存的用户设置
+func GetUserSettings(userID int) (json.RawMessage, error) {
+	var settings json.RawMessage
+	err := db.QueryRow("SELECT userSettings FROM userdatatable WHERE userID = ?", userID).Scan(&settings)
+	return settings, err
+}
+
+// SaveLastSeen 保存用户最后在线的时间
+func SaveLastSeen(userID int, lastSeen int64) error {
+	_, err := db.Exec("UPDATE userdatatable SET lastSeen = ? WHERE userID = ?", lastSeen, userID)
+	return err
+}
+
+// SavePresence 保存用户设置的在线状态，下次登录时恢复
+func SavePresence(userID int, status string) error {
+	_, err := db.Exec("UPDATE userdatatable SET presence = ? WHERE userID = ?", status, userID)
+	return err
+}
+
+// GetLastSeen 批量获取用户最后在线的时间，从未离线过的用户为0
+func GetLastSeen(userIDs []int) (map[int]int64, error) {
+	result := make(map[int]int64, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	rows, err := db.Query("SELECT userID, lastSeen FROM userdatatable WHERE userID IN ("+placeholders(len(userIDs))+")", intArgs(userIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int
+		var lastSeen int64
+		err = rows.Scan(&userID, &lastSeen)
+		if err != nil {
+			return nil, err
+		}
+		result[userID] = lastSeen
+	}
+	return result, rows.Err()
+}
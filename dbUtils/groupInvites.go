@@ -0,0 +1,106 @@
+package dbUtils
+
+import (
+	"database/sql"
+	"errors"
+	jsonprovider "jsonProvider"
+	"time"
+)
+
+// ErrGroupInviteHandled 入群邀请已被接受或拒绝
+var ErrGroupInviteHandled = errors.New("群邀请已处理")
+
+const groupInviteColumns = "inviteID, groupID, inviterID, inviteeID, state, createTime, updateTime"
+
+// CreateGroupInvite 保存一条待处理的入群邀请，返回inviteID
+func CreateGroupInvite(groupID int, inviterID int, inviteeID int) (int, error) {
+	now := time.Now().UnixNano()
+	result, err := db.Exec("INSERT INTO groupinvites (groupID, inviterID, inviteeID, state, createTime, updateTime) VALUES (?, ?, ?, ?, ?, ?)", groupID, inviterID, inviteeID, jsonprovider.GroupInvitePending, now, now)
+	if err != nil {
+		return 0, err
+	}
+	inviteID, err := result.LastInsertId()
+	return int(inviteID), err
+}
+
+// GetGroupInvite 获取入群邀请，不存在时返回 sql.ErrNoRows
+func GetGroupInvite(inviteID int) (*jsonprovider.GroupInvite, error) {
+	return scanGroupInvite(db.QueryRow("SELECT "+groupInviteColumns+" FROM groupinvites WHERE inviteID = ?", inviteID))
+}
+
+// FindPendingGroupInvite 查找用户收到的某个群聊的待处理邀请，不存在时返回 sql.ErrNoRows
+func FindPendingGroupInvite(groupID int, inviteeID int) (*jsonprovider.GroupInvite, error) {
+	return scanGroupInvite(db.QueryRow("SELECT "+groupInviteColumns+" FROM groupinvites WHERE groupID = ? AND inviteeID = ? AND state = ? ORDER BY inviteID DESC LIMIT 1", groupID, inviteeID, jsonprovider.GroupInvitePending))
+}
+
+// GetPendingGroupInvites 获取用户收到的待处理入群邀请
+func GetPendingGroupInvites(userID int) ([]jsonprovider.GroupInvite, error) {
+	rows, err := db.Query("SELECT "+groupInviteColumns+" FROM groupinvites WHERE inviteeID = ? AND state = ? ORDER BY inviteID", userID, jsonprovider.GroupInvitePending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []jsonprovider.GroupInvite{}
+	for rows.Next() {
+		invite, err := scanGroupInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *invite)
+	}
+	return invites, rows.Err()
+}
+
+// DeclineGroupInvite 将待处理的邀请标记为已拒绝，邀请已被处理时返回 ErrGroupInviteHandled
+func DeclineGroupInvite(inviteID int) error {
+	return updateGroupInviteState(db, inviteID, jsonprovider.GroupInviteDeclined)
+}
+
+// AcceptGroupInvite 在事务中将待处理的邀请标记为已接受并修改群成员，邀请已被处理时返回 ErrGroupInviteHandled
+// modify 返回错误时不做任何修改
+func AcceptGroupInvite(invite *jsonprovider.GroupInvite, modify func(membership *GroupMembership) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	err = updateGroupInviteState(tx, invite.InviteID, jsonprovider.GroupInviteAccepted)
+	if err != nil {
+		return err
+	}
+	err = updateGroupMembership(tx, int(invite.GroupID), modify)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func updateGroupInviteState(exec interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, inviteID int, state int) error {
+	result, err := exec.Exec("UPDATE groupinvites SET state = ?, updateTime = ? WHERE inviteID = ? AND state = ?", state, time.Now().UnixNano(), inviteID, jsonprovider.GroupInvitePending)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrGroupInviteHandled
+	}
+	return nil
+}
+
+func scanGroupInvite(row interface {
+	Scan(dest ...interface{}) error
+}) (*jsonprovider.GroupInvite, error) {
+	var invite jsonprovider.GroupInvite
+	err := row.Scan(&invite.InviteID, &invite.GroupID, &invite.InviterID, &invite.InviteeID, &invite.State, &invite.CreateTime, &invite.UpdateTime)
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
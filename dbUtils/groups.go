@@ -0,0 +1,256 @@
+package dbUtils
+
+import (
+	"database/sql"
+	"encoding/json"
+	jsonprovider "jsonProvider"
+	"time"
+)
+
+// GroupMembership 群主、群成员以及成员的角色和禁言状态
+type GroupMembership struct {
+	Master  int
+	Members []int
+	Roles   map[int]string // 管理员与协管员的角色，群主与普通成员不在其中
+	Mutes   map[int]int64  // 被禁言的成员及禁言截止时间（Unix秒）
+	// Permissions 群设置中按角色覆盖的权限，只读，修改需通过 UpdateGroupSettings
+	Permissions map[string][]string
+}
+
+// IsMember 判断用户是否为群成员
+func (m *GroupMembership) IsMember(userID int) bool {
+	return ContainsMember(m.Members, userID)
+}
+
+// Role 获取群成员的角色，调用前需确认用户为群成员
+func (m *GroupMembership) Role(userID int) string {
+	if userID == m.Master {
+		return jsonprovider.GroupRoleMaster
+	}
+	if role, ok := m.Roles[userID]; ok {
+		return role
+	}
+	return jsonprovider.GroupRoleMember
+}
+
+// IsMuted 判断群成员在now时是否处于禁言状态
+func (m *GroupMembership) IsMuted(userID int, now time.Time) bool {
+	until, ok := m.Mutes[userID]
+	return ok && now.Unix() < until
+}
+
+// RemoveMember 将用户移出群聊，同时清除其角色与禁言状态
+func (m *GroupMembership) RemoveMember(userID int) {
+	members := make([]int, 0, len(m.Members))
+	for _, member := range m.Members {
+		if member != userID {
+			members = append(members, member)
+		}
+	}
+	m.Members = members
+	delete(m.Roles, userID)
+	delete(m.Mutes, userID)
+}
+
+// GetGroupMembers 获取群成员ID列表
+func GetGroupMembers(groupID int) ([]int, error) {
+	membership, err := GetGroupMembership(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return membership.Members, nil
+}
+
+// GetGroupMembership 获取群主、群成员及成员角色
+func GetGroupMembership(groupID int) (*GroupMembership, error) {
+	var groupMaster int
+	var groupMembersJSON, groupRolesJSON, groupMutesJSON, groupSettingsJSON []byte
+	err := db.QueryRow("SELECT groupMaster, groupMembers, groupRoles, groupMutes, groupSettings FROM groupdatatable WHERE groupID = ?", groupID).Scan(&groupMaster, &groupMembersJSON, &groupRolesJSON, &groupMutesJSON, &groupSettingsJSON)
+	if err != nil {
+		return nil, err
+	}
+	return parseGroupMembership(groupMaster, groupMembersJSON, groupRolesJSON, groupMutesJSON, groupSettingsJSON)
+}
+
+// UpdateGroupMembership 在事务中锁定群聊并修改群主、成员列表及成员角色，modify 返回错误时不做任何修改
+func UpdateGroupMembership(groupID int, modify func(membership *GroupMembership) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	err = updateGroupMembership(tx, groupID, modify)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func updateGroupMembership(tx *sql.Tx, groupID int, modify func(membership *GroupMembership) error) error {
+	var groupMaster int
+	var groupMembersJSON, groupRolesJSON, groupMutesJSON, groupSettingsJSON []byte
+	err := tx.QueryRow("SELECT groupMaster, groupMembers, groupRoles, groupMutes, groupSettings FROM groupdatatable WHERE groupID = ? FOR UPDATE", groupID).Scan(&groupMaster, &groupMembersJSON, &groupRolesJSON, &groupMutesJSON, &groupSettingsJSON)
+	if err != nil {
+		return err
+	}
+	membership, err := parseGroupMembership(groupMaster, groupMembersJSON, groupRolesJSON, groupMutesJSON, groupSettingsJSON)
+	if err != nil {
+		return err
+	}
+
+	err = modify(membership)
+	if err != nil {
+		return err
+	}
+	groupMembersJSON, err = json.Marshal(membership.Members)
+	if err != nil {
+		return err
+	}
+	groupRolesJSON, err = json.Marshal(membership.Roles)
+	if err != nil {
+		return err
+	}
+	groupMutesJSON, err = json.Marshal(membership.Mutes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE groupdatatable SET groupMaster = ?, groupMembers = ?, groupRoles = ?, groupMutes = ? WHERE groupID = ?", membership.Master, groupMembersJSON, groupRolesJSON, groupMutesJSON, groupID)
+	return err
+}
+
+// BreakGroup 群主解散群聊，同时删除待处理的入群邀请，返回解散前的群成员
+// 群聊不存在或masterID不是群主时返回 sql.ErrNoRows
+func BreakGroup(groupID int, masterID int) ([]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	var groupMaster int
+	var groupMembersJSON, groupRolesJSON, groupMutesJSON, groupSettingsJSON []byte
+	err = tx.QueryRow("SELECT groupMaster, groupMembers, groupRoles, groupMutes, groupSettings FROM groupdatatable WHERE groupID = ? AND groupMaster = ? FOR UPDATE", groupID, masterID).Scan(&groupMaster, &groupMembersJSON, &groupRolesJSON, &groupMutesJSON, &groupSettingsJSON)
+	if err != nil {
+		return nil, err
+	}
+	membership, err := parseGroupMembership(groupMaster, groupMembersJSON, groupRolesJSON, groupMutesJSON, groupSettingsJSON)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("DELETE FROM groupdatatable WHERE groupID = ?", groupID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("DELETE FROM groupinvites WHERE groupID = ? AND state = ?", groupID, jsonprovider.GroupInvitePending)
+	if err != nil {
+		return nil, err
+	}
+	return membership.Members, tx.Commit()
+}
+
+// ContainsMember 判断用户是否在成员列表中
+func ContainsMember(members []int, userID int) bool {
+	for _, member := range members {
+		if member == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func parseGroupMembership(groupMaster int, groupMembersJSON []byte, groupRolesJSON []byte, groupMutesJSON []byte, groupSettingsJSON []byte) (*GroupMembership, error) {
+	membership := &GroupMembership{
+		Master:  groupMaster,
+		Members: []int{},
+		Roles:   make(map[int]string),
+		Mutes:   make(map[int]int64),
+	}
+	if len(groupMembersJSON) != 0 {
+		err := json.Unmarshal(groupMembersJSON, &membership.Members)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(groupRolesJSON) != 0 {
+		err := json.Unmarshal(groupRolesJSON, &membership.Roles)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(groupMutesJSON) != 0 {
+		err := json.Unmarshal(groupMutesJSON, &membership.Mutes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(groupSettingsJSON) != 0 {
+		var settings jsonprovider.GroupSettings
+		err := json.Unmarshal(groupSettingsJSON, &settings)
+		if err != nil {
+			return nil, err
+		}
+		membership.Permissions = settings.Permissions
+	}
+	return membership, nil
+}
+
+// GetGroupSettings 获取群设置，群名称、头像和简介以对应字段为准，未设置的选项使用默认值
+func GetGroupSettings(groupID int) (*jsonprovider.GroupSettings, error) {
+	return scanGroupSettings(db.QueryRow("SELECT groupName, groupAvatar, groupExplaination, groupSettings FROM groupdatatable WHERE groupID = ?", groupID))
+}
+
+// UpdateGroupSettings 在事务中锁定群聊并修改群设置，modify 返回错误时不做任何修改
+func UpdateGroupSettings(groupID int, modify func(settings *jsonprovider.GroupSettings) error) (*jsonprovider.GroupSettings, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	settings, err := scanGroupSettings(tx.QueryRow("SELECT groupName, groupAvatar, groupExplaination, groupSettings FROM groupdatatable WHERE groupID = ? FOR UPDATE", groupID))
+	if err != nil {
+		return nil, err
+	}
+	err = modify(settings)
+	if err != nil {
+		return nil, err
+	}
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE groupdatatable SET groupName = ?, groupAvatar = ?, groupExplaination = ?, groupSettings = ? WHERE groupID = ?", settings.Name, settings.Avatar, settings.Explanation, settingsJSON, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return settings, tx.Commit()
+}
+
+func scanGroupSettings(row *sql.Row) (*jsonprovider.GroupSettings, error) {
+	var groupName, groupExplaination string
+	var groupAvatar sql.NullString
+	var groupSettingsJSON []byte
+	err := row.Scan(&groupName, &groupAvatar, &groupExplaination, &groupSettingsJSON)
+	if err != nil {
+		return nil, err
+	}
+	settings := &jsonprovider.GroupSettings{}
+	if len(groupSettingsJSON) != 0 {
+		err = json.Unmarshal(groupSettingsJSON, settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	settings.Name = groupName
+	settings.Avatar = groupAvatar.String
+	settings.Explanation = groupExplaination
+	if settings.JoinPolicy == "" {
+		// 没有保存过入群方式的群聊（包括旧版创建的群聊）只能通过邀请加入，避免通过猜测群ID直接加入
+		settings.JoinPolicy = jsonprovider.GroupJoinInvite
+	}
+	if settings.HistoryVisibility == "" {
+		settings.HistoryVisibility = jsonprovider.GroupHistoryJoined
+	}
+	return settings, nil
+}
@@ -0,0 +1,689 @@
+package dbUtils
+
+import (
+	"database/sql"
+	"encoding/json"
+	jsonprovider "jsonProvider"
+	"logger"
+	"strings"
+	"time"
+)
+
+// 消息对每个接收者的投递状态，保存在 messagedeliveries 表中
+const (
+	DeliveryPending   = iota // 尚未送达，离线同步时下发
+	DeliveryDelivered        // 接收方已确认收到
+	DeliveryRead             // 接收方已读
+)
+
+// messageColumns 查询消息时读取的字段，与 scanMessage 的顺序一致
+const messageColumns = "m.messageID, m.senderID, m.receiverID, m.groupID, m.time, m.messageBody, m.messageType, m.recallTime, m.editTime, m.replyTo, m.threadRoot, m.mentions, m.mentionAll, m.kind, m.content"
+
+// SaveMessageToDB 将私聊消息写入消息表，并为接收方创建待投递记录，返回messageID
+func SaveMessageToDB(message *jsonprovider.Message) (int, error) {
+	return saveMessage(message, []int{message.ReceiverID})
+}
+
+// SaveGroupMessageToDB 将群消息写入消息表，并为除发送者外的群成员创建待投递记录，返回messageID
+// 被@的成员另外记录在 messagementions 表中，用于查询未读的@消息
+func SaveGroupMessageToDB(message *jsonprovider.Message, members []int) (int, error) {
+	recipients := make([]int, 0, len(members))
+	for _, member := range members {
+		if member != message.SenderID {
+			recipients = append(recipients, member)
+		}
+	}
+	return saveMessage(message, recipients)
+}
+
+func saveMessage(message *jsonprovider.Message, recipients []int) (int, error) {
+	timestamp := time.Now().UnixNano() //纳秒事件戳
+	var mentions []byte
+	if len(message.Mentions) > 0 {
+		var err error
+		mentions, err = json.Marshal(message.Mentions)
+		if err != nil {
+			return 0, err
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error("保存消息时开启事务失败", err)
+		return 0, err
+	}
+	defer rollback(tx)
+
+	var content []byte
+	if len(message.Content) > 0 {
+		content = message.Content
+	}
+	result, err := tx.Exec("INSERT INTO messages (senderID,receiverID,groupID,messageBody,time,messageType,replyTo,threadRoot,mentions,mentionAll,kind,content) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)",
+		message.SenderID, message.ReceiverID, message.GroupID, message.MessageBody, timestamp, message.MessageType, message.ReplyTo, message.ThreadRoot, mentions, message.MentionAll, message.Kind, content)
+	if err != nil {
+		logger.Error("保存消息时出现错误", err)
+		return 0, err
+	}
+	messageID, err := result.LastInsertId()
+	if err != nil {
+		logger.Error("获取插入消息的ID时出现错误", err)
+		return 0, err
+	}
+
+	for _, recipient := range recipients {
+		_, err = tx.Exec("INSERT INTO messagedeliveries (messageID,userID,state,updateTime) VALUES (?,?,?,?)", messageID, recipient, DeliveryPending, timestamp)
+		if err != nil {
+			logger.Error("保存消息投递记录时出现错误", err)
+			return 0, err
+		}
+	}
+
+	mentioned := message.Mentions
+	if message.MentionAll {
+		mentioned = recipients
+	}
+	for _, userID := range mentioned {
+		_, err = tx.Exec("INSERT IGNORE INTO messagementions (messageID,userID,groupID,readTime) VALUES (?,?,?,0)", messageID, userID, message.GroupID)
+		if err != nil {
+			logger.Error("保存@记录时出现错误", err)
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("保存消息时提交事务失败", err)
+		return 0, err
+	}
+	return int(messageID), nil
+}
+
+// MarkMessageDelivered 将消息对某个接收者的投递状态标记为已送达
+func MarkMessageDelivered(messageID int, userID int) error {
+	_, err := db.Exec("UPDATE messagedeliveries SET state = ?, updateTime = ? WHERE messageID = ? AND userID = ? AND state = ?", DeliveryDelivered, time.Now().UnixNano(), messageID, userID, DeliveryPending)
+	return err
+}
+
+// MarkMessageDeliveredTo 将消息对多个接收者的投递状态标记为已送达，用于已成功推送给在线用户的群消息和通知
+func MarkMessageDeliveredTo(messageID int, userIDs []int) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	args := append([]interface{}{DeliveryDelivered, time.Now().UnixNano(), messageID, DeliveryPending}, intArgs(userIDs)...)
+	_, err := db.Exec("UPDATE messagedeliveries SET state = ?, updateTime = ? WHERE messageID = ? AND state = ? AND userID IN ("+placeholders(len(userIDs))+")", args...)
+	return err
+}
+
+// MarkMessagesDeliveredUpTo 将用户messageID不大于maxMessageID的待投递消息全部标记为已送达
+func MarkMessagesDeliveredUpTo(userID int, maxMessageID int) error {
+	_, err := db.Exec("UPDATE messagedeliveries SET state = ?, updateTime = ? WHERE userID = ? AND state = ? AND messageID <= ?", DeliveryDelivered, time.Now().UnixNano(), userID, DeliveryPending, maxMessageID)
+	return err
+}
+
+// GetMessage 获取消息，不存在时返回 sql.ErrNoRows
+func GetMessage(messageID int) (*jsonprovider.Message, error) {
+	return scanMessage(db.QueryRow("SELECT "+messageColumns+" FROM messages m WHERE m.messageID = ?", messageID))
+}
+
+// IsMessageRecipient 判断用户是否为消息的接收者
+func IsMessageRecipient(messageID int, userID int) (bool, error) {
+	var exists int
+	err := db.QueryRow("SELECT 1 FROM messagedeliveries WHERE messageID = ? AND userID = ?", messageID, userID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetMessageRecipients 获取消息的所有接收者
+func GetMessageRecipients(messageID int) ([]int, error) {
+	rows, err := db.Query("SELECT userID FROM messagedeliveries WHERE messageID = ?", messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []int
+	for rows.Next() {
+		var userID int
+		err = rows.Scan(&userID)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, userID)
+	}
+	return recipients, rows.Err()
+}
+
+// RecallMessage 在事务中将消息标记为已撤回，并保存一条撤回通知投递给recipients，返回撤回通知的messageID
+// 消息不存在或已被撤回时返回 sql.ErrNoRows
+func RecallMessage(message *jsonprovider.Message, operatorID int, noticeBody string, noticeType int, recipients []int) (int, error) {
+	timestamp := time.Now().UnixNano()
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer rollback(tx)
+
+	result, err := tx.Exec("UPDATE messages SET recallTime = ? WHERE messageID = ? AND recallTime = 0", timestamp, message.MessageID)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	noticeID, err := saveNotice(tx, operatorID, message, noticeBody, noticeType, recipients, timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return noticeID, tx.Commit()
+}
+
+// EditMessage 在事务中保存消息的旧版本并修改消息内容，同时保存一条编辑通知投递给recipients，返回编辑通知的messageID
+// 消息不存在或已被撤回时返回 sql.ErrNoRows
+func EditMessage(message *jsonprovider.Message, messageBody string, noticeBody string, noticeType int, recipients []int, timestamp int64) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer rollback(tx)
+
+	var previousBody sql.NullString
+	err = tx.QueryRow("SELECT messageBody FROM messages WHERE messageID = ? AND recallTime = 0 FOR UPDATE", message.MessageID).Scan(&previousBody)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("INSERT INTO messageedits (messageID, messageBody, editTime) VALUES (?, ?, ?)", message.MessageID, previousBody, timestamp)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("UPDATE messages SET messageBody = ?, editTime = ? WHERE messageID = ?", messageBody, timestamp, message.MessageID)
+	if err != nil {
+		return 0, err
+	}
+
+	noticeID, err := saveNotice(tx, message.SenderID, message, noticeBody, noticeType, recipients, timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return noticeID, tx.Commit()
+}
+
+// GetMessageEdits 获取消息的历史版本，按编辑时间升序排列
+func GetMessageEdits(messageID int) ([]jsonprovider.MessageEdit, error) {
+	rows, err := db.Query("SELECT messageBody, editTime FROM messageedits WHERE messageID = ? ORDER BY editID", messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edits := []jsonprovider.MessageEdit{}
+	for rows.Next() {
+		var edit jsonprovider.MessageEdit
+		var messageBody sql.NullString
+		err = rows.Scan(&messageBody, &edit.EditTime)
+		if err != nil {
+			return nil, err
+		}
+		edit.MessageBody = messageBody.String
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
+}
+
+// saveNotice 在同一会话中保存一条由operatorID发出的通知消息，并为recipients创建待投递记录
+func saveNotice(tx *sql.Tx, operatorID int, message *jsonprovider.Message, noticeBody string, noticeType int, recipients []int, timestamp int64) (int, error) {
+	result, err := tx.Exec("INSERT INTO messages (senderID,receiverID,groupID,messageBody,time,messageType,kind) VALUES (?,?,?,?,?,?,?)", operatorID, message.ReceiverID, message.GroupID, noticeBody, timestamp, noticeType, jsonprovider.MessageKindSystem)
+	if err != nil {
+		return 0, err
+	}
+	noticeID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, recipient := range recipients {
+		_, err = tx.Exec("INSERT INTO messagedeliveries (messageID,userID,state,updateTime) VALUES (?,?,?,?)", noticeID, recipient, DeliveryPending, timestamp)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int(noticeID), nil
+}
+
+// GetPendingMessages 获取尚未送达用户的消息，按messageID升序最多返回limit条
+func GetPendingMessages(userID int, limit int) ([]jsonprovider.Message, error) {
+	rows, err := db.Query("SELECT "+messageColumns+" FROM messages m JOIN messagedeliveries d ON d.messageID = m.messageID WHERE d.userID = ? AND d.state = ? ORDER BY m.messageID LIMIT ?", userID, DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// GetMessagesBetweenUsers 获取两个用户在指定时间段内的、类型在messageTypes中的私聊记录
+func GetMessagesBetweenUsers(userID int, otherUserID int, startTime int, endTime int, messageTypes []int) ([]jsonprovider.Message, error) {
+	args := append([]interface{}{userID, otherUserID, otherUserID, userID, startTime, endTime}, intArgs(messageTypes)...)
+	rows, err := db.Query("SELECT "+messageColumns+" FROM messages m WHERE m.groupID = 0 AND ((m.senderID = ? AND m.receiverID = ?) OR (m.senderID = ? AND m.receiverID = ?)) AND m.time BETWEEN ? AND ? AND m.messageType IN ("+placeholders(len(messageTypes))+") ORDER BY m.messageID", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// GetGroupMessages 获取群聊在指定时间段内的、类型在messageTypes中的消息，onlyReceived 为true时只返回用户发送或入群后收到的消息
+func GetGroupMessages(userID int, groupID int, startTime int, endTime int, onlyReceived bool, messageTypes []int) ([]jsonprovider.Message, error) {
+	query := "SELECT " + messageColumns + " FROM messages m WHERE m.groupID = ? AND m.time BETWEEN ? AND ? AND m.messageType IN (" + placeholders(len(messageTypes)) + ")"
+	args := append([]interface{}{groupID, startTime, endTime}, intArgs(messageTypes)...)
+	if onlyReceived {
+		query += " AND (m.senderID = ? OR EXISTS (SELECT 1 FROM messagedeliveries d WHERE d.messageID = m.messageID AND d.userID = ?))"
+		args = append(args, userID, userID)
+	}
+	rows, err := db.Query(query+" ORDER BY m.messageID", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// GetThreadMessages 获取话题的根消息及其所有回复，onlyReceived 为true时只返回用户发送或收到的消息
+func GetThreadMessages(userID int, threadRoot int, onlyReceived bool) ([]jsonprovider.Message, error) {
+	query := "SELECT " + messageColumns + " FROM messages m WHERE (m.messageID = ? OR m.threadRoot = ?)"
+	args := []interface{}{threadRoot, threadRoot}
+	if onlyReceived {
+		query += " AND (m.senderID = ? OR EXISTS (SELECT 1 FROM messagedeliveries d WHERE d.messageID = m.messageID AND d.userID = ?))"
+		args = append(args, userID, userID)
+	}
+	rows, err := db.Query(query+" ORDER BY m.messageID", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// GetUnreadMentions 获取@用户且尚未读取的、未撤回的群消息，groupID 为0时返回所有群聊的
+func GetUnreadMentions(userID int, groupID int) ([]jsonprovider.Message, error) {
+	query := "SELECT " + messageColumns + " FROM messages m JOIN messagementions mm ON mm.messageID = m.messageID WHERE mm.userID = ? AND mm.readTime = 0 AND m.recallTime = 0"
+	args := []interface{}{userID}
+	if groupID != 0 {
+		query += " AND mm.groupID = ?"
+		args = append(args, groupID)
+	}
+	rows, err := db.Query(query+" ORDER BY m.messageID", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// MarkMentionsRead 将用户在群聊中messageID不大于maxMessageID的@消息标记为已读
+func MarkMentionsRead(userID int, groupID int, maxMessageID int) error {
+	_, err := db.Exec("UPDATE messagementions SET readTime = ? WHERE userID = ? AND groupID = ? AND messageID <= ? AND readTime = 0", time.Now().UnixNano(), userID, groupID, maxMessageID)
+	return err
+}
+
+// MarkConversationRead 将用户在会话中messageID不大于maxMessageID的消息标记为已读
+// 返回新标记为已读的消息的发送者（去重）及其中最大的messageID，没有新标记为已读的消息时返回 nil, 0
+// groupID 为0时表示与peerID的私聊
+func MarkConversationRead(userID int, peerID int, groupID int, maxMessageID int) ([]int, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rollback(tx)
+
+	rows, err := tx.Query("SELECT d.messageID, m.senderID FROM messagedeliveries d JOIN messages m ON m.messageID = d.messageID WHERE d.userID = ? AND d.state < ? AND d.messageID <= ? AND m.groupID = ? AND (m.groupID <> 0 OR m.senderID = ?) FOR UPDATE",
+		userID, DeliveryRead, maxMessageID, groupID, peerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var messageIDs, senders []int
+	lastReadID := 0
+	for rows.Next() {
+		var messageID, senderID int
+		err = rows.Scan(&messageID, &senderID)
+		if err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		messageIDs = append(messageIDs, messageID)
+		if !ContainsMember(senders, senderID) {
+			senders = append(senders, senderID)
+		}
+		if messageID > lastReadID {
+			lastReadID = messageID
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(messageIDs) == 0 {
+		return nil, 0, nil
+	}
+
+	args := append([]interface{}{DeliveryRead, time.Now().UnixNano(), userID}, intArgs(messageIDs)...)
+	_, err = tx.Exec("UPDATE messagedeliveries SET state = ?, updateTime = ? WHERE userID = ? AND messageID IN ("+placeholders(len(messageIDs))+")", args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+	return senders, lastReadID, nil
+}
+
+// GetConversations 获取用户的所有会话，按最后一条消息的ID降序排列
+// 只有类型为messageType的、未撤回的消息计入最后一条消息和未读数
+func GetConversations(userID int, messageType int) ([]jsonprovider.Conversation, error) {
+	rows, err := db.Query(`SELECT c.peerID, c.groupID, MAX(c.messageID) AS lastMessageID FROM (
+			SELECT m.messageID, IF(m.senderID = ?, m.receiverID, m.senderID) AS peerID, 0 AS groupID FROM messages m
+				WHERE m.groupID = 0 AND (m.senderID = ? OR m.receiverID = ?) AND m.messageType = ? AND m.recallTime = 0
+			UNION ALL
+			SELECT m.messageID, 0 AS peerID, m.groupID FROM messages m
+				WHERE m.groupID <> 0 AND m.messageType = ? AND m.recallTime = 0 AND (m.senderID = ? OR EXISTS (SELECT 1 FROM messagedeliveries d WHERE d.messageID = m.messageID AND d.userID = ?))
+		) c GROUP BY c.peerID, c.groupID ORDER BY lastMessageID DESC`,
+		userID, userID, userID, messageType, messageType, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []jsonprovider.Conversation{}
+	var lastMessageIDs []int
+	for rows.Next() {
+		var conversation jsonprovider.Conversation
+		var lastMessageID int
+		err = rows.Scan(&conversation.UserID, &conversation.GroupID, &lastMessageID)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+		lastMessageIDs = append(lastMessageIDs, lastMessageID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	unread, err := getUnreadCounts(userID, messageType)
+	if err != nil {
+		return nil, err
+	}
+	lastMessages, err := getMessagesByID(lastMessageIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range conversations {
+		conversation := &conversations[i]
+		conversation.UnreadCount = unread[[2]int{conversation.UserID, conversation.GroupID}]
+		conversation.LastMessage = lastMessages[lastMessageIDs[i]]
+	}
+	return conversations, nil
+}
+
+// getUnreadCounts 按会话统计用户未读的消息数，键为 [私聊对方ID, 群ID]
+func getUnreadCounts(userID int, messageType int) (map[[2]int]int, error) {
+	rows, err := db.Query("SELECT IF(m.groupID = 0, m.senderID, 0) AS peerID, m.groupID, COUNT(*) FROM messagedeliveries d JOIN messages m ON m.messageID = d.messageID WHERE d.userID = ? AND d.state < ? AND m.messageType = ? AND m.recallTime = 0 GROUP BY peerID, m.groupID",
+		userID, DeliveryRead, messageType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unread := make(map[[2]int]int)
+	for rows.Next() {
+		var peerID, groupID, count int
+		err = rows.Scan(&peerID, &groupID, &count)
+		if err != nil {
+			return nil, err
+		}
+		unread[[2]int{peerID, groupID}] = count
+	}
+	return unread, rows.Err()
+}
+
+// getMessagesByID 批量获取消息，返回messageID到消息的映射
+func getMessagesByID(messageIDs []int) (map[int]*jsonprovider.Message, error) {
+	result := make(map[int]*jsonprovider.Message, len(messageIDs))
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	rows, err := db.Query("SELECT "+messageColumns+" FROM messages m WHERE m.messageID IN ("+placeholders(len(messageIDs))+")", intArgs(messageIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		result[messages[i].MessageID] = &messages[i]
+	}
+	return result, nil
+}
+
+// loadReadCounts 为消息附加已读的接收者数量
+func loadReadCounts(messages []jsonprovider.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(messages))
+	messageIDs := make([]int, 0, len(messages))
+	for i, message := range messages {
+		index[message.MessageID] = i
+		messageIDs = append(messageIDs, message.MessageID)
+	}
+	args := append(intArgs(messageIDs), DeliveryRead)
+	rows, err := db.Query("SELECT messageID, COUNT(*) FROM messagedeliveries WHERE messageID IN ("+placeholders(len(messageIDs))+") AND state = ? GROUP BY messageID", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID, count int
+		err = rows.Scan(&messageID, &count)
+		if err != nil {
+			return err
+		}
+		messages[index[messageID]].ReadCount = count
+	}
+	return rows.Err()
+}
+
+// placeholders 生成n个以逗号分隔的SQL占位符
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func intArgs(values []int) []interface{} {
+	args := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return args
+}
+
+// GetMessagesAfter 获取messageID大于cursor的、用户发送或接收的消息，按messageID升序最多返回limit条
+func GetMessagesAfter(userID int, cursor int, limit int) ([]jsonprovider.Message, error) {
+	rows, err := db.Query("SELECT "+messageColumns+" FROM messages m WHERE m.messageID > ? AND (m.senderID = ? OR EXISTS (SELECT 1 FROM messagedeliveries d WHERE d.messageID = m.messageID AND d.userID = ?)) ORDER BY m.messageID LIMIT ?", cursor, userID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// GetSyncPointer 获取用户某台设备已同步到的messageID，从未同步过时返回0
+func GetSyncPointer(userID int, deviceID string) (int, error) {
+	var lastMessageID int
+	err := db.QueryRow("SELECT lastMessageID FROM syncpointers WHERE userID = ? AND deviceID = ?", userID, deviceID).Scan(&lastMessageID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return lastMessageID, err
+}
+
+// SaveSyncPointer 保存用户某台设备已同步到的messageID，游标只会前进
+func SaveSyncPointer(userID int, deviceID string, lastMessageID int) error {
+	_, err := db.Exec("INSERT INTO syncpointers (userID, deviceID, lastMessageID, updateTime) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE lastMessageID = GREATEST(lastMessageID, VALUES(lastMessageID)), updateTime = VALUES(updateTime)", userID, deviceID, lastMessageID, time.Now().UnixNano())
+	return err
+}
+
+func scanMessages(rows *sql.Rows) ([]jsonprovider.Message, error) {
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Error("SQL错误", err)
+		}
+	}(rows)
+
+	var messages []jsonprovider.Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *message)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	err = loadReadCounts(messages)
+	if err != nil {
+		return nil, err
+	}
+	return messages, loadReactions(messages)
+}
+
+// scanMessage 读取一条消息，已撤回的消息不返回消息内容
+func scanMessage(row interface {
+	Scan(dest ...interface{}) error
+}) (*jsonprovider.Message, error) {
+	var message jsonprovider.Message
+	var recallTime int64
+	var mentions, content []byte
+	err := row.Scan(&message.MessageID, &message.SenderID, &message.ReceiverID, &message.GroupID, &message.Time, &message.MessageBody, &message.MessageType, &recallTime, &message.EditedAt, &message.ReplyTo, &message.ThreadRoot, &mentions, &message.MentionAll, &message.Kind, &content)
+	if err != nil {
+		return nil, err
+	}
+	if len(mentions) > 0 {
+		err = json.Unmarshal(mentions, &message.Mentions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(content) > 0 {
+		message.Content = content
+	}
+	message.Edited = message.EditedAt > 0
+	if recallTime > 0 {
+		message.Recalled = true
+		message.MessageBody = ""
+		message.Content = nil
+	}
+	return &message, nil
+}
+
+func rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone {
+		logger.Error("回滚事务失败", err)
+	}
+}
+
+// migrateOfflineMessages 将旧版 offlinemessages / offlinegroupmessages 表中的消息迁移到统一的消息表
+// 迁移完成后旧表被清空并重命名为 *_migrated
+func migrateOfflineMessages() {
+	if CheckTableExistence(db, _BasicChatDBName, "offlinemessages") != 0 {
+		logger.Warn("发现旧版离线消息表，开始迁移到消息表")
+		migrateLegacyTable("offlinemessages", false)
+	}
+	if CheckTableExistence(db, _BasicChatDBName, "offlinegroupmessages") != 0 {
+		logger.Warn("发现旧版离线群消息表，开始迁移到消息表")
+		migrateLegacyTable("offlinegroupmessages", true)
+	}
+}
+
+func migrateLegacyTable(tableName string, isGroup bool) {
+	rows, err := db.Query("SELECT senderID, receiverID, time, messageBody, messageType FROM " + tableName + " ORDER BY messageID")
+	if err != nil {
+		logger.Error("读取", tableName, "失败:", err)
+		return
+	}
+	type legacyMessage struct {
+		senderID, receiverID int
+		time                 sql.NullInt64
+		messageBody          sql.NullString
+		messageType          sql.NullInt64
+	}
+	var legacyMessages []legacyMessage
+	for rows.Next() {
+		var message legacyMessage
+		err = rows.Scan(&message.senderID, &message.receiverID, &message.time, &message.messageBody, &message.messageType)
+		if err != nil {
+			logger.Error("读取", tableName, "失败:", err)
+			_ = rows.Close()
+			return
+		}
+		legacyMessages = append(legacyMessages, message)
+	}
+	err = rows.Close()
+	if err != nil {
+		logger.Error("SQL错误", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error("迁移", tableName, "时开启事务失败:", err)
+		return
+	}
+	defer rollback(tx)
+	for _, message := range legacyMessages {
+		receiverID, groupID := message.receiverID, 0
+		if isGroup {
+			// 旧版群消息表的 receiverID 保存的是群ID，未记录投递状态，只作为历史消息迁移
+			receiverID, groupID = 0, message.receiverID
+		}
+		result, err := tx.Exec("INSERT INTO messages (senderID,receiverID,groupID,messageBody,time,messageType) VALUES (?,?,?,?,?,?)", message.senderID, receiverID, groupID, message.messageBody, message.time, message.messageType)
+		if err != nil {
+			logger.Error("迁移", tableName, "失败:", err)
+			return
+		}
+		if isGroup {
+			continue
+		}
+		messageID, err := result.LastInsertId()
+		if err != nil {
+			logger.Error("迁移", tableName, "失败:", err)
+			return
+		}
+		_, err = tx.Exec("INSERT INTO messagedeliveries (messageID,userID,state,updateTime) VALUES (?,?,?,?)", messageID, receiverID, DeliveryPending, message.time)
+		if err != nil {
+			logger.Error("迁移", tableName, "失败:", err)
+			return
+		}
+	}
+	// 与插入在同一事务中清空旧表，重命名失败时下次启动也不会重复迁移
+	_, err = tx.Exec("DELETE FROM " + tableName)
+	if err != nil {
+		logger.Error("清空", tableName, "失败:", err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("迁移", tableName, "时提交事务失败:", err)
+		return
+	}
+
+	_, err = db.Exec("RENAME TABLE " + tableName + " TO " + tableName + "_migrated")
+	if err != nil {
+		logger.Error("重命名", tableName, "失败:", err)
+		return
+	}
+	logger.Info("已迁移", len(legacyMessages), "条消息，旧表已重命名为", tableName+"_migrated")
+}
@@ -0,0 +1,86 @@
+package dbUtils
+
+import (
+	"config"
+	jsonprovider "jsonProvider"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// openTestDB 使用独立的测试数据库初始化数据表，测试结束后恢复数据库名与连接。需要设置环境变量
+// IRIDESCENCE_TEST_DB_ADDRESS、IRIDESCENCE_TEST_DB_ACCOUNT、IRIDESCENCE_TEST_DB_PASSWORD，未设置时跳过测试
+func openTestDB(t *testing.T) {
+	t.Helper()
+	address := os.Getenv("IRIDESCENCE_TEST_DB_ADDRESS")
+	if address == "" {
+		t.Skip("未设置 IRIDESCENCE_TEST_DB_ADDRESS，跳过需要MySQL的测试")
+	}
+	var conf config.Config
+	conf.DataBaseSettings.Address = address
+	conf.DataBaseSettings.Account = os.Getenv("IRIDESCENCE_TEST_DB_ACCOUNT")
+	conf.DataBaseSettings.Password = os.Getenv("IRIDESCENCE_TEST_DB_PASSWORD")
+	conf.SaltLength = 16
+	savedName, savedDB := _BasicChatDBName, db
+	t.Cleanup(func() {
+		if db != savedDB {
+			_ = db.Close()
+		}
+		_BasicChatDBName, db = savedName, savedDB
+	})
+	_BasicChatDBName = "basic_chat_test"
+	DbInit(conf)
+	if err := db.Ping(); err != nil {
+		t.Fatal("连接测试数据库失败:", err)
+	}
+}
+
+func containsMessage(messages []jsonprovider.Message, messageID int) bool {
+	for _, message := range messages {
+		if message.MessageID == messageID {
+			return true
+		}
+	}
+	return false
+}
+
+// 成功推送给在线成员的群消息不应再作为离线消息下发，离线成员仍然可以获取
+func TestGroupMessageDeliveredToOnlineMemberIsNotPending(t *testing.T) {
+	openTestDB(t)
+	const sender, online, offline = 900001, 900002, 900003
+
+	messageID, err := SaveGroupMessageToDB(&jsonprovider.Message{
+		SenderID:    sender,
+		GroupID:     900001,
+		MessageBody: "hello",
+		Kind:        jsonprovider.MessageKindText,
+	}, []int{sender, online, offline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM messagedeliveries WHERE messageID = ?", messageID)
+		_, _ = db.Exec("DELETE FROM messages WHERE messageID = ?", messageID)
+	})
+
+	err = MarkMessageDeliveredTo(messageID, []int{online})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := GetPendingMessages(online, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsMessage(pending, messageID) {
+		t.Errorf("在线成员已收到的群消息 %d 仍在离线消息中", messageID)
+	}
+	pending, err = GetPendingMessages(offline, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsMessage(pending, messageID) {
+		t.Errorf("离线成员的离线消息中缺少群消息 %d", messageID)
+	}
+}
@@ -0,0 +1,63 @@
+package dbUtils
+
+import (
+	jsonprovider "jsonProvider"
+	"time"
+)
+
+// AddReaction 为消息添加用户的表情回应，返回是否新增，重复添加不报错
+func AddReaction(messageID int, userID int, emoji string) (bool, error) {
+	result, err := db.Exec("INSERT IGNORE INTO messagereactions (messageID, userID, emoji, createTime) VALUES (?, ?, ?, ?)", messageID, userID, emoji, time.Now().UnixNano())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RemoveReaction 删除用户对消息的表情回应，返回是否删除
+func RemoveReaction(messageID int, userID int, emoji string) (bool, error) {
+	result, err := db.Exec("DELETE FROM messagereactions WHERE messageID = ? AND userID = ? AND emoji = ?", messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CountReaction 获取消息某个表情回应的数量
+func CountReaction(messageID int, emoji string) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM messagereactions WHERE messageID = ? AND emoji = ?", messageID, emoji).Scan(&count)
+	return count, err
+}
+
+// loadReactions 为消息附加按表情汇总的回应数量
+func loadReactions(messages []jsonprovider.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(messages))
+	messageIDs := make([]int, 0, len(messages))
+	for i, message := range messages {
+		index[message.MessageID] = i
+		messageIDs = append(messageIDs, message.MessageID)
+	}
+	rows, err := db.Query("SELECT messageID, emoji, COUNT(*) FROM messagereactions WHERE messageID IN ("+placeholders(len(messageIDs))+") GROUP BY messageID, emoji ORDER BY messageID, MIN(createTime)", intArgs(messageIDs)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int
+		var reaction jsonprovider.Reaction
+		err = rows.Scan(&messageID, &reaction.Emoji, &reaction.Count)
+		if err != nil {
+			return err
+		}
+		message := &messages[index[messageID]]
+		message.Reactions = append(message.Reactions, reaction)
+	}
+	return rows.Err()
+}
@@ -2,6 +2,8 @@ package fileserver
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"logger"
@@ -13,6 +15,8 @@ import (
 
 const uploadDirectory = "./uploads"
 
+var ErrInvalidUploadHash = errors.New("无效的文件哈希")
+
 // CopyAndRenameFile 复制并重命名文件
 func CopyAndRenameFile(filePath, newFilePath string) error {
 	srcFile, err := os.Open(filePath)
@@ -35,6 +39,29 @@ func CopyAndRenameFile(filePath, newFilePath string) error {
 	return nil
 }
 
+// StatUpload 根据上传时返回的文件哈希获取文件大小与根据文件内容检测出的类型
+func StatUpload(hash string) (size int64, contentType string, err error) {
+	if decoded, decodeErr := hex.DecodeString(hash); decodeErr != nil || len(decoded) != sha256.Size {
+		return 0, "", ErrInvalidUploadHash
+	}
+	file, err := os.Open(filepath.Join(uploadDirectory, hash))
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	buffer := make([]byte, 512)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, "", err
+	}
+	return info.Size(), http.DetectContentType(buffer[:n]), nil
+}
+
 // HandleFileUpload 处理文件上传
 func HandleFileUpload(w http.ResponseWriter, r *http.Request) {
 
@@ -28,13 +28,13 @@ func LoadConfig(conf config.Config) {
 			UserName:       "OtherServer",
 			TokenExpiry:    time.Now().Add(1024 * time.Hour),
 		}
-		Tokens[token] = &user
-		UserToTokens[user.UserId] = &token
+		storeToken(token, &user)
 	}
 }
 
 type User jsonprovider.User
 
+// Tokens 与 UserToTokens 由 tokensLock 保护，其他包需通过 LookupToken 等函数访问
 var Tokens map[string]*User = make(map[string]*User)
 var UserToTokens map[int]*string = make(map[int]*string)
 
@@ -205,8 +205,7 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 		user.TokenExpiry = expiry
 
 		// 将token和用户信息存入内存
-		Tokens[token] = &user
-		UserToTokens[user.UserId] = &token
+		storeToken(token, &user)
 
 		// 返回token给用户
 		w.WriteHeader(http.StatusOK)
@@ -239,10 +238,10 @@ func HandleRequest(w http.ResponseWriter, r *http.Request) {
 	token := r.FormValue("token")
 	command := r.FormValue("command")
 
-	user, ok := Tokens[token]
+	user, ok := LookupToken(token)
 
 	// 验证token是否有效
-	if !CheckTokenExpiry(token) || ok == false {
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		fmtPrintF(w, "Invalid token")
 		return
@@ -260,10 +259,10 @@ func HandleRequest(w http.ResponseWriter, r *http.Request) {
 		case "verifyToken":
 			targetToken := r.FormValue("targetToken")
 			logger.Debug("远端服务器尝试验证用户token", targetToken)
-			targetUser, ok := Tokens[targetToken]
+			targetUser, ok := LookupToken(targetToken)
 
 			// 验证token是否有效
-			if !CheckTokenExpiry(token) || ok == false {
+			if !ok {
 				w.WriteHeader(http.StatusUnauthorized)
 				fmtPrintF(w, "Invalid token")
 				return
@@ -403,6 +402,10 @@ func HandleRequest(w http.ResponseWriter, r *http.Request) {
 			fmtPrintF(w, "Failed to get user data")
 			return
 		}
+		if targetUserIDint != user.UserId {
+			// 好友列表包含备注、分组等仅对本人可见的信息
+			targetUser.UserFriendList = nil
+		}
 
 		// 发送响应
 		w.WriteHeader(http.StatusOK)
@@ -429,23 +432,6 @@ func containsLetterAndNumber(s string) bool {
 func containsLowerAndUpperCase(s string) bool {
 	return strings.ToLower(s) != s && strings.ToUpper(s) != s
 }
-func CheckTokenExpiry(token string) bool {
-	user, ok := Tokens[token]
-	if !ok {
-		// Token不存在
-		return false
-	}
-
-	// 检查Token是否已经过期
-	if time.Now().After(user.TokenExpiry) {
-		// Token已经过期
-		delete(UserToTokens, user.UserId)
-		return false
-	}
-
-	// Token没有过期
-	return true
-}
 func AllowCORS(w http.ResponseWriter, r *http.Request) bool {
 	w.Header().Add("Access-Control-Allow-Origin", "*")                                                                                   // 允许任何来源
 	w.Header().Add("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")                                                    // 允许的 HTTP 方法
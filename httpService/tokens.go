@@ -0,0 +1,104 @@
+package httpService
+
+import (
+	"sync"
+	"time"
+)
+
+// tokensLock 保护 Tokens 与 UserToTokens，HTTP处理函数、WebSocket登录与控制台命令会并发访问
+var tokensLock sync.Mutex
+
+// storeToken 保存签发的token
+func storeToken(token string, user *User) {
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+	Tokens[token] = user
+	UserToTokens[user.UserId] = &token
+}
+
+// LookupToken 获取token对应的用户信息副本，token不存在或已过期时返回false
+func LookupToken(token string) (User, bool) {
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+	user, ok := lookupToken(token)
+	if !ok {
+		return User{}, false
+	}
+	return *user, true
+}
+
+// lookupToken 调用前需持有 tokensLock，token已过期时清除用户到token的映射
+func lookupToken(token string) (*User, bool) {
+	user, ok := Tokens[token]
+	if !ok {
+		// Token不存在
+		return nil, false
+	}
+
+	// 检查Token是否已经过期
+	if time.Now().After(user.TokenExpiry) {
+		// Token已经过期
+		delete(UserToTokens, user.UserId)
+		return nil, false
+	}
+
+	// Token没有过期
+	return user, true
+}
+
+// CheckTokenExpiry 判断token是否存在且没有过期
+func CheckTokenExpiry(token string) bool {
+	_, ok := LookupToken(token)
+	return ok
+}
+
+// InvalidateToken 使token失效，token不存在时返回false
+func InvalidateToken(token string) bool {
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+	user, ok := Tokens[token]
+	if !ok {
+		return false
+	}
+	delete(Tokens, token)
+	if userToken, ok := UserToTokens[user.UserId]; ok && *userToken == token {
+		delete(UserToTokens, user.UserId)
+	}
+	return true
+}
+
+// SetTokenPermission 修改用户当前token的权限等级，用户没有token时返回false
+func SetTokenPermission(userID int, permission uint) bool {
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+	token, ok := UserToTokens[userID]
+	if !ok {
+		return false
+	}
+	user, ok := Tokens[*token]
+	if !ok {
+		return false
+	}
+	user.UserPermission = permission
+	return true
+}
+
+// TokenCount 返回已签发的token数量
+func TokenCount() int {
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+	return len(Tokens)
+}
+
+// ValidTokens 返回所有未过期的token及其用户信息副本
+func ValidTokens() map[string]User {
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+	tokens := make(map[string]User, len(Tokens))
+	for token := range Tokens {
+		if user, ok := lookupToken(token); ok {
+			tokens[token] = *user
+		}
+	}
+	return tokens
+}
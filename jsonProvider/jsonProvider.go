@@ -27,6 +27,44 @@ func StringifyJSON(data interface{}) []byte {
 
 	return jsonData
 }
+
+// MergePatch 按照 RFC 7386 将patch合并到target中，patch中值为null的字段会被删除
+func MergePatch(target []byte, patch []byte) ([]byte, error) {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+
+	var targetValue, patchValue interface{}
+	if len(target) != 0 {
+		err := json.Unmarshal(target, &targetValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := json.Unmarshal(patch, &patchValue)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatchValue(targetValue, patchValue))
+}
+
+func mergePatchValue(target interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = make(map[string]interface{})
+	}
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+			continue
+		}
+		targetMap[key] = mergePatchValue(targetMap[key], value)
+	}
+	return targetMap
+}
+
 func WriteJSONToWriter(writer io.Writer, data interface{}) {
 	// 创建 JSON 编码器
 	json := jsoniter.ConfigCompatibleWithStandardLibrary
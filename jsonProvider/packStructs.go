@@ -10,6 +10,12 @@ type StandardJSONPack struct {
 	Content interface{} `json:"content"`
 }
 
+// ErrorResponse 命令执行失败时的通用响应
+type ErrorResponse struct {
+	State   bool   `json:"state"`
+	Message string `json:"message"`
+}
+
 type HeartBeatPack struct {
 	TimeStamp int `json:"timeStamp"`
 }
@@ -17,22 +23,31 @@ type HeartBeatPack struct {
 type LoginRequest struct {
 	Userid                 int    `json:"userId"`
 	Password               string `json:"password"`
+	Token                  string `json:"token"` //HTTP登录接口签发的token，提供时忽略密码
 	UseArtificialHeartPack bool   `json:"heartPack"`
+	DeviceID               string `json:"deviceId"` //设备ID，同一设备重复登录会顶掉旧连接，为空时由服务器生成
+	Platform               string `json:"platform"`
 }
 type LoginResponse struct {
-	State    bool   `json:"state"`
-	Message  string `json:"message"`
-	UserData User   `json:"userData"`
+	State    bool         `json:"state"`
+	Message  string       `json:"message"`
+	UserData User         `json:"userData"`
+	DeviceID string       `json:"deviceId"`
+	Settings UserSettings `json:"settings"`
 }
 type SignUpRequest struct {
 	UserName string `json:"userName"`
 	Password string `json:"password"`
 }
 type SendMessageRequest struct {
-	TargetID         int    `json:"targetId"`    //消息接收人
-	RequestID        int    `json:"requestId"`   //request ID由客户端生成
-	MessageBody      string `json:"messageBody"` //消息体
-	RequestTimeStamp int    `json:"time"`        //判断请求是否合法，是否超时
+	TargetID         int             `json:"targetId"`    //消息接收人
+	RequestID        int             `json:"requestId"`   //request ID由客户端生成
+	MessageBody      string          `json:"messageBody"` //消息体
+	RequestTimeStamp int             `json:"time"`        //判断请求是否合法，是否超时
+	ReplyTo          int             `json:"replyTo"`     //引用的消息ID，为0表示不引用
+	ThreadRoot       int             `json:"threadRoot"`  //所属话题的根消息ID，为0表示不属于任何话题
+	Kind             string          `json:"kind"`        //消息种类，为空时为 text
+	Content          json.RawMessage `json:"content"`     //与消息种类对应的元数据
 }
 
 // SendMessageResponse 实现ACK机制
@@ -48,21 +63,38 @@ const (
 	ServerSendError
 	UserIsNotOnline
 	UserReceived
+	MessageSent    //已发给在线的接收方，等待接收方ACK，确认后通过messageEvent推送UserReceived
+	MessageInvalid //消息不合法，如引用的消息不存在或不可见，消息不会保存
 )
 
 type SendMessageToTargetPack struct {
-	SenderID    int    `json:"senderId"`
-	MessageID   int    `json:"messageId"`
-	MessageBody string `json:"messageBody"`
-	TimeStamp   int    `json:"time"`
+	SenderID    int             `json:"senderId"`
+	MessageID   int             `json:"messageId"`
+	MessageBody string          `json:"messageBody"`
+	TimeStamp   int             `json:"time"`
+	ReplyTo     int             `json:"replyTo"`
+	ThreadRoot  int             `json:"threadRoot"`
+	Kind        string          `json:"kind"`
+	Content     json.RawMessage `json:"content,omitempty"`
 }
+
+// SendMessagePackResponseFromUser 接收方收到消息后回发的ACK
 type SendMessagePackResponseFromUser struct {
 	MessageID int  `json:"messageId"`
 	State     bool `json:"state"`
 }
 
+// MessageStateEvent 消息状态变化时推送给发送方
+type MessageStateEvent struct {
+	MessageID int `json:"messageId"`
+	State     int `json:"state"`
+	TimeStamp int `json:"time"`
+}
+
+// AddFriendRequest 向对方发送好友申请，对方接受后双方成为好友
 type AddFriendRequest struct {
-	FriendID int `json:"friendId"`
+	FriendID int    `json:"friendId"`
+	Message  string `json:"message"` // 验证消息
 }
 
 type DeleteFriendRequest struct {
@@ -74,8 +106,60 @@ type CheckUserOnlineStateRequest struct {
 }
 
 type CheckUserOnlineStateResponse struct {
-	UserID   int  `json:"userId"`
-	IsOnline bool `json:"isOnline"`
+	UserID   int            `json:"userId"`
+	IsOnline bool           `json:"isOnline"`
+	Status   string         `json:"status"` //在线状态，不可见时为offline
+	Devices  []OnlineDevice `json:"devices"`
+}
+
+// 在线状态
+const (
+	PresenceOnline       = "online"
+	PresenceAway         = "away"
+	PresenceDoNotDisturb = "dnd"
+	PresenceOffline      = "offline"
+)
+
+// SetPresenceRequest 设置自己的在线状态，Status 为 online、away 或 dnd
+type SetPresenceRequest struct {
+	Status string `json:"status"`
+}
+
+// SetPresenceResponse 设置成功后同时推送给用户的所有在线会话
+type SetPresenceResponse struct {
+	Status  string `json:"status"`
+	Success bool   `json:"success"`
+}
+
+// GetPresenceRequest 批量查询用户的在线状态
+type GetPresenceRequest struct {
+	UserIDs []int `json:"userIds"`
+}
+
+// Presence 用户的在线状态，LastSeen 为最后在线的时间，不可见或从未离线时为0
+type Presence struct {
+	UserID   int    `json:"userId"`
+	Status   string `json:"status"`
+	LastSeen int64  `json:"lastSeen"`
+}
+
+type GetPresenceResponse struct {
+	Presences []Presence `json:"presences"`
+}
+
+// UserStateEvent 用户上线、离线或修改在线状态时推送给可以看到其在线状态的在线好友
+type UserStateEvent struct {
+	UserID    int    `json:"userId"`
+	Status    string `json:"status"`
+	LastSeen  int64  `json:"lastSeen"`
+	TimeStamp int    `json:"time"`
+}
+
+// OnlineDevice 用户的一个在线设备
+type OnlineDevice struct {
+	DeviceID    string `json:"deviceId"`
+	Platform    string `json:"platform"`
+	ConnectTime int64  `json:"connectTime"`
 }
 
 type GetUserDataRequest struct {
@@ -91,6 +175,18 @@ type GetUserDataResponse struct {
 	UserFriendList json.RawMessage `json:"userFriendList"`
 }
 
+// ChangeSettingsRequest Settings 为 JSON Merge Patch 格式的部分设置，值为null的字段恢复默认值
+type ChangeSettingsRequest struct {
+	Settings json.RawMessage `json:"settings"`
+}
+
+// ChangeSettingsResponse 修改成功后同时推送给用户的所有在线会话
+type ChangeSettingsResponse struct {
+	Success  bool          `json:"success"`
+	Message  string        `json:"message"`
+	Settings *UserSettings `json:"settings,omitempty"`
+}
+
 type ChangeAvatarRequest struct {
 	NewAvatar string `json:"newAvatar"`
 }
@@ -113,12 +209,232 @@ type GetMessagesWithUserResponse struct {
 }
 
 type Message struct {
+	MessageID   int             `json:"messageId"`
+	SenderID    int             `json:"senderId"`
+	ReceiverID  int             `json:"receiverId"` //私聊消息的接收方，群消息为0
+	GroupID     int             `json:"groupId"`    //群消息所属的群，私聊消息为0
+	Time        int             `json:"time"`
+	MessageBody string          `json:"messageBody"`
+	MessageType int             `json:"messageType"`
+	Recalled    bool            `json:"recalled"` //已撤回的消息不返回消息内容
+	Edited      bool            `json:"edited"`
+	EditedAt    int             `json:"editedAt"` //最后一次编辑的时间，未编辑时为0
+	ReplyTo     int             `json:"replyTo"`
+	ThreadRoot  int             `json:"threadRoot"`
+	Kind        string          `json:"kind"`
+	Content     json.RawMessage `json:"content,omitempty"`   //与消息种类对应的元数据，已撤回的消息不返回
+	Reactions   []Reaction      `json:"reactions,omitempty"` //按表情汇总的回应数量
+	Mentions    []int           `json:"mentions,omitempty"`  //群消息中被@的成员
+	MentionAll  bool            `json:"mentionAll,omitempty"`
+	ReadCount   int             `json:"readCount"` //已读的接收者数量，私聊消息为0或1
+}
+
+// 消息种类
+const (
+	MessageKindText     = "text"
+	MessageKindImage    = "image"    //Content 为 ImageContent，messageBody 为可选的说明文字
+	MessageKindFile     = "file"     //Content 为 FileContent
+	MessageKindLocation = "location" //Content 为 LocationContent
+	MessageKindCard     = "card"     //Content 为 CardContent
+	MessageKindSystem   = "system"   //系统通知，只有管理员可以发送，服务器生成的撤回、编辑通知同样使用该种类
+)
+
+// ImageContent 图片消息，Hash 为上传文件后返回的文件哈希
+type ImageContent struct {
+	Hash   string `json:"hash"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"` //由服务器根据上传的文件填写
+	Mime   string `json:"mime"` //由服务器根据上传的文件填写
+}
+
+// FileContent 文件消息，Size 必须与上传的文件大小一致
+type FileContent struct {
+	Hash string `json:"hash"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Mime string `json:"mime"`
+}
+
+// LocationContent 位置消息
+type LocationContent struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name"`
+	Address   string  `json:"address"`
+}
+
+// CardContent 名片消息，UserName 与 UserAvatar 由服务器在发送时填写
+type CardContent struct {
+	UserID     int    `json:"userId"`
+	UserName   string `json:"userName"`
+	UserAvatar string `json:"userAvatar"`
+}
+
+// Reaction 消息的一种表情回应及其数量
+type Reaction struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+// ReactionRequest 添加或删除自己对消息的表情回应
+type ReactionRequest struct {
+	MessageID int    `json:"messageId"`
+	Emoji     string `json:"emoji"`
+}
+
+type ReactionResponse struct {
+	MessageID int    `json:"messageId"`
+	Emoji     string `json:"emoji"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+}
+
+// 表情回应事件类型
+const (
+	ReactionEventAdd    = "add"
+	ReactionEventRemove = "remove"
+)
+
+// ReactionEvent 表情回应变化时推送给会话的在线参与者，Count 为变化后该表情的回应数量
+type ReactionEvent struct {
+	MessageID  int    `json:"messageId"`
+	ReceiverID int    `json:"receiverId"`
+	GroupID    int    `json:"groupId"`
+	Event      string `json:"event"`
+	UserID     int    `json:"userId"`
+	Emoji      string `json:"emoji"`
+	Count      int    `json:"count"`
+	TimeStamp  int    `json:"time"`
+}
+
+// MarkReadRequest 将会话中messageID不大于MessageID的消息标记为已读，GroupID 为0时表示与UserID的私聊
+type MarkReadRequest struct {
+	UserID    int   `json:"userId"`
+	GroupID   int64 `json:"groupId"`
+	MessageID int   `json:"messageId"`
+}
+
+// MarkReadResponse 标记成功后同时推送给用户的所有在线会话
+type MarkReadResponse struct {
+	UserID    int   `json:"userId"`
+	GroupID   int64 `json:"groupId"`
+	MessageID int   `json:"messageId"`
+	Success   bool  `json:"success"`
+}
+
+// ReadReceiptEvent 已读回执，私聊时推送给对方，群聊时推送给在线的群成员
+type ReadReceiptEvent struct {
+	UserID    int   `json:"userId"` //读取消息的用户
+	GroupID   int64 `json:"groupId"`
+	MessageID int   `json:"messageId"`
+	TimeStamp int   `json:"time"`
+}
+
+// Conversation 会话及其未读数，私聊时UserID为对方ID，群聊时GroupID为群ID
+type Conversation struct {
+	UserID      int      `json:"userId"`
+	GroupID     int      `json:"groupId"`
+	UnreadCount int      `json:"unreadCount"`
+	LastMessage *Message `json:"lastMessage"`
+}
+
+type GetConversationsResponse struct {
+	Conversations []Conversation `json:"conversations"`
+}
+
+// 临时信号类型，信号不会保存，只转发给在线的用户
+const (
+	SignalTyping    = "typing"
+	SignalStopped   = "stopped"
+	SignalRecording = "recording"
+	SignalViewing   = "viewing"
+)
+
+// SendSignalRequest 向私聊对方或群聊发送临时信号，GroupID 为0时发送给UserID
+type SendSignalRequest struct {
+	UserID  int    `json:"userId"`
+	GroupID int64  `json:"groupId"`
+	Type    string `json:"type"`
+}
+
+// SignalEvent 转发给接收方的临时信号
+type SignalEvent struct {
+	SenderID  int    `json:"senderId"`
+	GroupID   int64  `json:"groupId"`
+	Type      string `json:"type"`
+	TimeStamp int    `json:"time"`
+}
+
+// GetThreadMessagesRequest 获取话题的根消息及其所有回复
+type GetThreadMessagesRequest struct {
+	ThreadRoot int `json:"threadRoot"`
+}
+
+type GetThreadMessagesResponse struct {
+	ThreadRoot int       `json:"threadRoot"`
+	Messages   []Message `json:"messages"`
+}
+
+// RecallMessageRequest 撤回私聊或群消息
+type RecallMessageRequest struct {
+	MessageID int `json:"messageId"`
+}
+
+type RecallMessageResponse struct {
+	MessageID int    `json:"messageId"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+}
+
+// EditMessageRequest 修改自己发送的消息内容
+type EditMessageRequest struct {
+	MessageID   int    `json:"messageId"`
+	MessageBody string `json:"messageBody"`
+}
+
+type EditMessageResponse struct {
+	MessageID int    `json:"messageId"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	EditedAt  int    `json:"editedAt"`
+}
+
+// MessageEditEvent 消息被编辑时推送给会话的所有参与者，离线的参与者通过编辑通知消息获取
+type MessageEditEvent struct {
 	MessageID   int    `json:"messageId"`
 	SenderID    int    `json:"senderId"`
 	ReceiverID  int    `json:"receiverId"`
-	Time        int    `json:"time"`
+	GroupID     int    `json:"groupId"`
+	MessageBody string `json:"messageBody"`
+	EditedAt    int    `json:"editedAt"`
+	NoticeID    int    `json:"noticeId"` //编辑通知消息的ID，收到推送即视为编辑通知已送达
+}
+
+// MessageEdit 消息被编辑前的一个版本
+type MessageEdit struct {
 	MessageBody string `json:"messageBody"`
-	MessageType int    `json:"messageType"`
+	EditTime    int64  `json:"editTime"` //被替换的时间
+}
+
+type GetMessageEditsRequest struct {
+	MessageID int `json:"messageId"`
+}
+
+type GetMessageEditsResponse struct {
+	MessageID int           `json:"messageId"`
+	Edits     []MessageEdit `json:"edits"`
+}
+
+// MessageRecallEvent 消息被撤回时推送给会话的所有参与者，离线的参与者通过撤回通知消息获取
+type MessageRecallEvent struct {
+	MessageID  int `json:"messageId"`
+	SenderID   int `json:"senderId"`
+	ReceiverID int `json:"receiverId"`
+	GroupID    int `json:"groupId"`
+	OperatorID int `json:"operatorId"`
+	TimeStamp  int `json:"time"`
+	NoticeID   int `json:"noticeId"` //撤回通知消息的ID，收到推送即视为撤回通知已送达
 }
 
 type CreateGroupRequest struct {
@@ -135,13 +451,152 @@ type BreakGroupRequest struct {
 }
 
 type BreakGroupResponse struct {
+	GroupID int64  `json:"groupId"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// GroupMemberRequest 邀请、踢出群成员及转让群主时使用，UserID 为被操作的用户
+type GroupMemberRequest struct {
 	GroupID int64 `json:"groupId"`
-	Success bool  `json:"success"`
+	UserID  int   `json:"userId"`
+}
+
+// GroupRequest 加入、退出群聊时使用
+type GroupRequest struct {
+	GroupID int64 `json:"groupId"`
+}
+
+// SetGroupMemberRoleRequest 群主设置群成员的角色
+type SetGroupMemberRoleRequest struct {
+	GroupID int64  `json:"groupId"`
+	UserID  int    `json:"userId"`
+	Role    string `json:"role"`
+}
+
+// MuteGroupMemberRequest 禁言群成员，MuteSeconds 不大于0时解除禁言
+type MuteGroupMemberRequest struct {
+	GroupID     int64 `json:"groupId"`
+	UserID      int   `json:"userId"`
+	MuteSeconds int64 `json:"muteSeconds"`
+}
+
+type GroupMemberResponse struct {
+	GroupID int64  `json:"groupId"`
+	UserID  int    `json:"userId"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
 }
+
+// 群成员变动事件类型
+const (
+	GroupEventJoin     = "join"
+	GroupEventInvite   = "invite"
+	GroupEventLeave    = "leave"
+	GroupEventKick     = "kick"
+	GroupEventTransfer = "transfer"
+	GroupEventRole     = "role"
+	GroupEventMute     = "mute"
+	GroupEventUnmute   = "unmute"
+	GroupEventSettings = "settings"
+	GroupEventBreak    = "break"
+)
+
+// GroupMemberEvent 群成员变动时推送给所有在线群成员及被操作的用户
+type GroupMemberEvent struct {
+	GroupID    int64  `json:"groupId"`
+	Event      string `json:"event"`
+	OperatorID int    `json:"operatorId"`
+	UserID     int    `json:"userId"`
+	Role       string `json:"role,omitempty"`      // 角色变动后的角色
+	MuteUntil  int64  `json:"muteUntil,omitempty"` // 禁言截止时间（Unix秒）
+	TimeStamp  int    `json:"time"`
+}
+
+// 入群邀请状态
+const (
+	GroupInvitePending = iota
+	GroupInviteAccepted
+	GroupInviteDeclined
+)
+
+// GroupInvite 入群邀请，被邀请人接受后才会加入群聊，状态变化时通过 groupInviteEvent 推送给邀请人和被邀请人
+type GroupInvite struct {
+	InviteID   int   `json:"inviteId"`
+	GroupID    int64 `json:"groupId"`
+	InviterID  int   `json:"inviterId"`
+	InviteeID  int   `json:"inviteeId"`
+	State      int   `json:"state"`
+	CreateTime int64 `json:"createTime"`
+	UpdateTime int64 `json:"updateTime"`
+}
+
+// GroupInviteActionRequest 接受或拒绝入群邀请
+type GroupInviteActionRequest struct {
+	InviteID int `json:"inviteId"`
+}
+
+type GroupInviteActionResponse struct {
+	InviteID int    `json:"inviteId"`
+	Success  bool   `json:"success"`
+	Message  string `json:"message"`
+}
+
+// GetGroupInvitesResponse 用户收到的待处理入群邀请
+type GetGroupInvitesResponse struct {
+	Invites []GroupInvite `json:"invites"`
+}
+
+// ChangeGroupSettingsRequest 修改群设置，只修改请求中出现的字段
+type ChangeGroupSettingsRequest struct {
+	GroupID           int64   `json:"groupId"`
+	Name              *string `json:"name"`
+	Avatar            *string `json:"avatar"`
+	Explanation       *string `json:"explanation"`
+	JoinPolicy        *string `json:"joinPolicy"`
+	MuteAll           *bool   `json:"muteAll"`
+	HistoryVisibility *string `json:"historyVisibility"`
+	// Permissions 只有群主可以修改，值为 null 的角色恢复默认权限
+	Permissions map[string][]string `json:"permissions"`
+}
+
+type ChangeGroupSettingsResponse struct {
+	GroupID  int64          `json:"groupId"`
+	Success  bool           `json:"success"`
+	Message  string         `json:"message"`
+	Settings *GroupSettings `json:"settings,omitempty"`
+}
+
+// GroupSettingsEvent 群设置修改后推送给所有在线群成员
+type GroupSettingsEvent struct {
+	GroupID    int64          `json:"groupId"`
+	Event      string         `json:"event"`
+	OperatorID int            `json:"operatorId"`
+	Settings   *GroupSettings `json:"settings"`
+	TimeStamp  int            `json:"time"`
+}
+
+type GetGroupMessagesRequest struct {
+	GroupID   int64 `json:"groupId"`
+	StartTime int   `json:"startTime"`
+	EndTime   int   `json:"endTime"`
+}
+
+type GetGroupMessagesResponse struct {
+	GroupID  int64     `json:"groupId"`
+	Messages []Message `json:"messages"`
+}
+
 type SendGroupMessageRequest struct {
-	GroupID     int64  `json:"groupId"`
-	MessageBody string `json:"messageBody"`
-	RequestID   int    `json:"requestId"`
+	GroupID     int64           `json:"groupId"`
+	MessageBody string          `json:"messageBody"`
+	RequestID   int             `json:"requestId"`
+	ReplyTo     int             `json:"replyTo"`    //引用的消息ID，为0表示不引用
+	ThreadRoot  int             `json:"threadRoot"` //所属话题的根消息ID，为0表示不属于任何话题
+	Mentions    []int           `json:"mentions"`   //被@的成员，必须是群成员
+	MentionAll  bool            `json:"mentionAll"` //@全体成员，只有群主和管理员可以使用
+	Kind        string          `json:"kind"`       //消息种类，为空时为 text
+	Content     json.RawMessage `json:"content"`    //与消息种类对应的元数据
 }
 
 type SendGroupMessageResponse struct {
@@ -152,16 +607,117 @@ type SendGroupMessageResponse struct {
 }
 
 type SendMessageToGroupPack struct {
-	SenderID    int    `json:"senderId"`
-	MessageID   int    `json:"messageId"`
-	MessageBody string `json:"messageBody"`
-	TimeStamp   int    `json:"timeStamp"`
+	SenderID    int             `json:"senderId"`
+	MessageID   int             `json:"messageId"`
+	MessageBody string          `json:"messageBody"`
+	TimeStamp   int             `json:"timeStamp"`
+	ReplyTo     int             `json:"replyTo"`
+	ThreadRoot  int             `json:"threadRoot"`
+	Kind        string          `json:"kind"`
+	Content     json.RawMessage `json:"content,omitempty"`
+	Mentions    []int           `json:"mentions,omitempty"`
+	MentionAll  bool            `json:"mentionAll"`
+	Mentioned   bool            `json:"mentioned"` //接收方是否被@，包括@全体成员
+}
+
+// GetUnreadMentionsRequest GroupID 为0时返回所有群聊中未读的@消息
+type GetUnreadMentionsRequest struct {
+	GroupID int64 `json:"groupId"`
+}
+
+type GetUnreadMentionsResponse struct {
+	GroupID  int64     `json:"groupId"`
+	Messages []Message `json:"messages"`
+}
+
+// MarkMentionsReadRequest 将群聊中messageID不大于MessageID的@消息标记为已读
+type MarkMentionsReadRequest struct {
+	GroupID   int64 `json:"groupId"`
+	MessageID int   `json:"messageId"`
+}
+
+type MarkMentionsReadResponse struct {
+	GroupID   int64 `json:"groupId"`
+	MessageID int   `json:"messageId"`
+	Success   bool  `json:"success"`
 }
 
 type AddFriendResponse struct {
-	UserID   int  `json:"userId"`
-	FriendID int  `json:"friendId"`
-	Success  bool `json:"success"`
+	UserID    int    `json:"userId"`
+	FriendID  int    `json:"friendId"`
+	Success   bool   `json:"success"`
+	RequestID int    `json:"requestId"`
+	Message   string `json:"message"`
+}
+
+// ChangeFriendSettingsRequest 修改自己为好友设置的信息，只修改请求中出现的字段
+type ChangeFriendSettingsRequest struct {
+	FriendID int     `json:"friendId"`
+	Remark   *string `json:"remark"`
+	Group    *string `json:"group"`
+	Muted    *bool   `json:"muted"`
+	Pinned   *bool   `json:"pinned"`
+}
+
+// ChangeFriendSettingsResponse 修改成功后同时推送给用户的所有在线会话
+type ChangeFriendSettingsResponse struct {
+	FriendID int     `json:"friendId"`
+	Success  bool    `json:"success"`
+	Message  string  `json:"message"`
+	Friend   *Friend `json:"friend,omitempty"`
+}
+
+// BlockUserRequest 屏蔽或取消屏蔽用户
+type BlockUserRequest struct {
+	UserID int `json:"userId"`
+}
+
+// BlockUserResponse 操作成功后同时推送给用户的所有在线会话
+type BlockUserResponse struct {
+	UserID  int    `json:"userId"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+type GetBlockListResponse struct {
+	BlockList []int `json:"blockList"`
+}
+
+// 好友申请状态
+const (
+	FriendRequestPending = iota
+	FriendRequestAccepted
+	FriendRequestRejected
+	FriendRequestCancelled
+	FriendRequestExpired
+)
+
+// FriendRequest 好友申请，状态变化时通过 friendRequestEvent 推送给双方
+type FriendRequest struct {
+	RequestID  int    `json:"requestId"`
+	SenderID   int    `json:"senderId"`
+	ReceiverID int    `json:"receiverId"`
+	Message    string `json:"message"`
+	State      int    `json:"state"`
+	CreateTime int64  `json:"createTime"`
+	UpdateTime int64  `json:"updateTime"`
+}
+
+// FriendRequestActionRequest 接受、拒绝或撤回好友申请
+type FriendRequestActionRequest struct {
+	RequestID int `json:"requestId"`
+}
+
+type FriendRequestActionResponse struct {
+	RequestID int    `json:"requestId"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+}
+
+// GetFriendRequestsResponse 用户收到的和发出的待处理好友申请
+type GetFriendRequestsResponse struct {
+	Incoming []FriendRequest `json:"incoming"`
+	Outgoing []FriendRequest `json:"outgoing"`
 }
 
 type DeleteFriendResponse struct {
@@ -169,9 +725,29 @@ type DeleteFriendResponse struct {
 	FriendID int  `json:"friendId"`
 	Success  bool `json:"success"`
 }
+
+// GetOfflineMessagesRequest 分页获取离线消息，已下发的消息视为已送达
+type GetOfflineMessagesRequest struct {
+	Limit int `json:"limit"`
+}
+
 type GetOfflineMessagesResponse struct {
 	State    bool      `json:"state"`
 	Messages []Message `json:"messages"`
+	HasMore  bool      `json:"hasMore"`
+}
+
+// SyncRequest 拉取cursor之后的消息，cursor为空时使用服务器保存的本设备游标
+// cursor同时表示客户端已收到该ID及之前的所有消息
+type SyncRequest struct {
+	Cursor *int `json:"cursor"`
+	Limit  int  `json:"limit"`
+}
+
+type SyncResponse struct {
+	Messages []Message `json:"messages"`
+	Cursor   int       `json:"cursor"` //本页最后一条消息的ID，下次同步时作为cursor
+	HasMore  bool      `json:"hasMore"`
 }
 type PublishPostRequest struct {
 	UserID  int    `json:"userId"`
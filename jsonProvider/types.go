@@ -3,14 +3,11 @@ package jsonprovider
 import (
 	"encoding/json"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 type User struct {
 	UserId         int             `json:"userId"`
 	TokenExpiry    time.Time       `json:"-"`
-	Conn           *websocket.Conn `json:"-"`
 	UserName       string          `json:"userName"`
 	UserAvatar     string          `json:"userAvatar"`
 	UserNote       string          `json:"userNote"`
@@ -18,14 +15,120 @@ type User struct {
 	UserFriendList json.RawMessage `json:"userFriendList"`
 }
 
+// Friend 好友及自己为该好友设置的信息，保存在 userdatatable.userFriendList 中
 type Friend struct {
-	UserID  int
-	AddTime int
+	UserID  int    `json:"userId"`
+	AddTime int    `json:"addTime"`
+	Remark  string `json:"remark"` // 备注名
+	Group   string `json:"group"`  // 好友分组
+	Muted   bool   `json:"muted"`  // 消息免打扰
+	Pinned  bool   `json:"pinned"` // 置顶
 }
 type FriendList []Friend
 
+// Find 查找好友，不存在时返回nil
+func (list FriendList) Find(userID int) *Friend {
+	for i := range list {
+		if list[i].UserID == userID {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+// Contains 判断用户是否在好友列表中
+func (list FriendList) Contains(userID int) bool {
+	return list.Find(userID) != nil
+}
+
+// 通知方式
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions" // 只通知@自己的消息，仅用于群消息
+	NotifyNone     = "none"
+)
+
+// 在线状态的可见范围
+const (
+	VisibleToEveryone = "everyone"
+	VisibleToFriends  = "friends"
+	VisibleToNobody   = "nobody"
+)
+
+// 谁可以添加我为好友
+const (
+	AddMeEveryone     = "everyone"
+	AddMeGroupMembers = "groupMembers" // 只有与我在同一群聊中的用户
+	AddMeNobody       = "nobody"
+)
+
+// 私聊消息策略，决定谁可以向用户发送私聊消息
+const (
+	DMPolicyDefault                = "default" // 使用服务器配置的策略
+	DMPolicyOpen                   = "open"
+	DMPolicyFriends                = "friends"
+	DMPolicyFriendsAndGroupMembers = "friendsAndGroupMembers" // 好友以及与用户在同一群聊中的成员
+)
+
+// NotificationSettings 通知偏好
+type NotificationSettings struct {
+	DirectMessages string `json:"directMessages"` // all 或 none
+	GroupMessages  string `json:"groupMessages"`  // all、mentions 或 none
+	FriendRequests string `json:"friendRequests"` // all 或 none
+}
+
+// UserSettings 用户设置，保存在 userdatatable.userSettings 中，默认值由 config.json 的 DefaultSettings 提供
 type UserSettings struct {
+	Notifications          NotificationSettings `json:"notifications"`
+	OnlineStatusVisibility string               `json:"onlineStatusVisibility"`
+	WhoCanAddMe            string               `json:"whoCanAddMe"`
+	DirectMessagePolicy    string               `json:"directMessagePolicy"`
+	Language               string               `json:"language"`
 }
+
+// 群成员角色
+const (
+	GroupRoleMaster    = "master"
+	GroupRoleAdmin     = "admin"
+	GroupRoleModerator = "moderator"
+	GroupRoleMember    = "member"
+)
+
+// 群权限名称，用于在群设置中按角色覆盖默认权限
+const (
+	GroupPermissionSend           = "send"           // 发送群消息
+	GroupPermissionInvite         = "invite"         // 邀请成员入群
+	GroupPermissionChangeSettings = "changeSettings" // 修改群设置
+	GroupPermissionMute           = "mute"           // 禁言成员
+	GroupPermissionKick           = "kick"           // 踢出成员
+	GroupPermissionRecall         = "recall"         // 撤回其他成员的消息
+	GroupPermissionMentionAll     = "mentionAll"     // @全体成员
+)
+
+// 入群方式
+const (
+	GroupJoinOpen   = "open"   // 任何人都可以直接加入
+	GroupJoinInvite = "invite" // 只能由群成员邀请加入
+)
+
+// 新成员可以查看的历史消息范围
+const (
+	GroupHistoryAll    = "all"    // 可以查看入群前的消息
+	GroupHistoryJoined = "joined" // 只能查看入群后的消息
+)
+
+// GroupSettings 群设置，保存在 groupdatatable.groupSettings 中
+type GroupSettings struct {
+	Name              string `json:"name"`
+	Avatar            string `json:"avatar"`
+	Explanation       string `json:"explanation"`
+	JoinPolicy        string `json:"joinPolicy"`
+	MuteAll           bool   `json:"muteAll"` // 全员禁言，拥有禁言权限的成员仍可发言
+	HistoryVisibility string `json:"historyVisibility"`
+	// Permissions 按角色覆盖的权限列表，键为 member、moderator 或 admin，未出现的角色使用默认权限
+	Permissions map[string][]string `json:"permissions,omitempty"`
+}
+
 type Group struct {
 	GroupName string
 	GroupID   int
@@ -59,6 +59,8 @@ func main() {
 
 	db := dbUtils.GetDBPtr()
 	wsService.LoadDB(db)
+	wsService.StartRedelivery()
+	wsService.StartSignalPruning()
 
 	logger.Info("服务器启动成功！")
 	commandSystem.StartListening()
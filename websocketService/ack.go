@@ -0,0 +1,134 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+	"logger"
+	"time"
+)
+
+const redeliveryCheckInterval = time.Second
+
+// Message 消息结构体,用于临时消息池，保存已发出但接收方尚未确认的消息
+type Message struct {
+	id          int
+	senderID    int
+	recipientID int
+	payload     []byte // 发给接收方的完整数据包，重发时原样发送
+	attempts    int
+	nextRetry   time.Time
+	deadline    time.Time // 超过该时间仍未确认则不再重发，等待接收方拉取离线消息
+}
+
+// trackMessage 将已发给在线接收方的消息加入ACK消息池，等待接收方确认
+func trackMessage(messageID int, senderID int, recipientID int, payload []byte) {
+	now := time.Now()
+	processingStateMessagesLock.Lock()
+	defer processingStateMessagesLock.Unlock()
+	processingStateMessages[messageID] = &Message{
+		id:          messageID,
+		senderID:    senderID,
+		recipientID: recipientID,
+		payload:     payload,
+		nextRetry:   now.Add(retryBackoff(0)),
+		deadline:    now.Add(time.Duration(configData.MessageAckDeadlineSeconds) * time.Second),
+	}
+}
+
+// retryBackoff 第attempts次重发前的等待时间，按指数增长
+func retryBackoff(attempts int) time.Duration {
+	backoff := time.Duration(configData.MessageAckTimeoutSeconds) * time.Second
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	maxBackoff := time.Duration(configData.MessageAckDeadlineSeconds) * time.Second
+	for i := 0; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// StartRedelivery 启动未确认消息的重发协程
+func StartRedelivery() {
+	go func() {
+		ticker := time.NewTicker(redeliveryCheckInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			redeliver(now)
+		}
+	}()
+}
+
+func redeliver(now time.Time) {
+	var retrying, expired []*Message
+	processingStateMessagesLock.Lock()
+	for id, message := range processingStateMessages {
+		if now.After(message.deadline) {
+			delete(processingStateMessages, id)
+			expired = append(expired, message)
+			continue
+		}
+		if now.After(message.nextRetry) {
+			message.attempts++
+			message.nextRetry = now.Add(retryBackoff(message.attempts))
+			retrying = append(retrying, message)
+		}
+	}
+	processingStateMessagesLock.Unlock()
+
+	for _, message := range retrying {
+		logger.Debug("消息", message.id, "未收到确认，第", message.attempts, "次重发")
+		_, err := sendMessageToUser(message.recipientID, message.payload)
+		if err != nil {
+			logger.Debug("消息重发失败", err)
+		}
+	}
+	for _, message := range expired {
+		// 消息投递状态仍为未送达，接收方下次拉取离线消息时获取
+		logger.Info("消息", message.id, "超时未确认，转为离线消息")
+		pushMessageState(message.senderID, message.id, jsonprovider.UserIsNotOnline)
+	}
+}
+
+// handleAckMessage 接收方确认收到消息
+func handleAckMessage(session *Session, request interface{}) {
+	req := request.(*jsonprovider.SendMessagePackResponseFromUser)
+	userID := session.User.UserId
+
+	processingStateMessagesLock.Lock()
+	message, ok := processingStateMessages[req.MessageID]
+	if ok && message.recipientID == userID {
+		delete(processingStateMessages, req.MessageID)
+	} else {
+		ok = false
+	}
+	processingStateMessagesLock.Unlock()
+
+	// 已送达的消息不再作为离线消息下发，不在消息池中的消息（如离线拉取的消息）也需要更新
+	err := dbUtils.MarkMessageDelivered(req.MessageID, userID)
+	if err != nil {
+		logger.Error("更新消息投递状态失败:", err)
+	}
+	if !ok {
+		return
+	}
+
+	state := jsonprovider.UserReceived
+	if !req.State {
+		state = jsonprovider.UserRefused
+	}
+	pushMessageState(message.senderID, message.id, state)
+}
+
+// pushMessageState 通过 messageEvent 通知发送方消息状态变化
+func pushMessageState(senderID int, messageID int, state int) {
+	event := jsonprovider.MessageStateEvent{
+		MessageID: messageID,
+		State:     state,
+		TimeStamp: int(time.Now().UnixNano()),
+	}
+	_, err := sendMessageToUser(senderID, jsonprovider.SdandarlizeJSON_byte(configData.Commands.MessageEvent, event))
+	if err != nil {
+		logger.Debug("消息状态推送失败", err)
+	}
+}
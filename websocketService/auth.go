@@ -0,0 +1,109 @@
+package websocketService
+
+import (
+	"dbUtils"
+	"encoding/json"
+	"hashUtils"
+	"httpService"
+	jsonprovider "jsonProvider"
+	"logger"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// tokenSubprotocolPrefix 通过 Sec-WebSocket-Protocol 携带token时子协议的前缀，其他子协议不会被当作token
+const tokenSubprotocolPrefix = "token."
+
+// handshakeToken 从查询参数 token 或 Sec-WebSocket-Protocol 请求头中以 token. 开头的子协议获取登录token
+// protocol 不为空时握手响应需要回显该子协议，否则浏览器会拒绝连接
+func handshakeToken(r *http.Request) (token string, protocol string) {
+	token = r.URL.Query().Get("token")
+	if token != "" {
+		return token, ""
+	}
+	for _, protocol := range websocket.Subprotocols(r) {
+		protocol = strings.TrimSpace(protocol)
+		token = strings.TrimPrefix(protocol, tokenSubprotocolPrefix)
+		if token != protocol && token != "" {
+			return token, protocol
+		}
+	}
+	return "", ""
+}
+
+// verifyToken 校验 /login 接口签发的token，返回token对应的用户ID
+func verifyToken(token string) (int, bool) {
+	user, ok := httpService.LookupToken(token)
+	if !ok {
+		return 0, false
+	}
+	return user.UserId, true
+}
+
+// verifyLoginRequest 校验首帧登录请求，携带token时优先使用token，否则校验密码
+func verifyLoginRequest(p *jsonprovider.LoginRequest) (int, bool) {
+	if p.Token != "" {
+		return verifyToken(p.Token)
+	}
+	if p.Userid == 0 || p.Password == "" {
+		return 0, false
+	}
+
+	passwordHash, passwordSalt, err := dbUtils.GetDBPasswordHash(p.Userid)
+	if err != nil {
+		logger.Error("读取数据库密码哈希值失败", err)
+		return 0, false
+	}
+	logger.Debug("登录时读取盐:", passwordSalt)
+	tryingPasswordHash := hashUtils.HashPassword(p.Password, passwordSalt)
+	logger.Debug("尝试哈希", tryingPasswordHash, "实际哈希", passwordHash)
+	return p.Userid, tryingPasswordHash == passwordHash
+}
+
+// loadUser 从数据库中获取用户信息并创建User结构体
+func loadUser(userID int) (*User, error) {
+	var username, userAvatar, userNote, presence string
+	var userPermission uint
+	var userFriendList, userSettings json.RawMessage
+	err := db.QueryRow("SELECT userName, userAvatar, userNote, userPermission, userFriendList, userSettings, presence FROM userdatatable WHERE userID = ?", userID).Scan(&username, &userAvatar, &userNote, &userPermission, &userFriendList, &userSettings, &presence)
+	if err != nil {
+		return nil, err
+	}
+	friends, err := dbUtils.ParseFriendList(userFriendList)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := parseUserSettings(userSettings)
+	if err != nil {
+		logger.Warn("用户", userID, "的设置无法解析，使用默认设置:", err)
+		settings = configData.UserSettings.DefaultSettings
+	}
+
+	return &User{
+		User: jsonprovider.User{
+			UserId:         userID,
+			UserName:       username,
+			UserAvatar:     userAvatar,
+			UserNote:       userNote,
+			UserPermission: userPermission,
+			UserFriendList: jsonprovider.StringifyJSON(friends),
+		},
+		Sessions: make(map[string]*Session),
+		settings: settings,
+		presence: presence,
+	}, nil
+}
+
+// sendLoginFailed 登录成功前写协程尚未启动，直接写入连接
+func sendLoginFailed(conn *websocket.Conn, message string) {
+	res := jsonprovider.LoginResponse{
+		State:   false,
+		Message: message,
+	}
+	err := conn.WriteMessage(websocket.TextMessage, jsonprovider.StringifyJSON(res))
+	if err != nil {
+		logger.Error("Failed to send message:", err)
+	}
+}
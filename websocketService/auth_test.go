@@ -0,0 +1,32 @@
+package websocketService
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandshakeToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		protocols    string
+		wantToken    string
+		wantProtocol string
+	}{
+		{"查询参数", "/ws?token=abc", "token.def", "abc", ""},
+		{"子协议", "/ws", "chat, token.def", "def", "token.def"},
+		{"没有前缀的子协议不是token", "/ws", "chat, def", "", ""},
+		{"只有前缀", "/ws", "token.", "", ""},
+		{"没有token", "/ws", "", "", ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.protocols != "" {
+			r.Header.Set("Sec-WebSocket-Protocol", test.protocols)
+		}
+		token, protocol := handshakeToken(r)
+		if token != test.wantToken || protocol != test.wantProtocol {
+			t.Errorf("%s: 得到 %q, %q，期望 %q, %q", test.name, token, protocol, test.wantToken, test.wantProtocol)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+	"logger"
+)
+
+// isBlockedBy 判断userID是否被otherUserID屏蔽，查询失败时按未屏蔽处理
+func isBlockedBy(userID int, otherUserID int) bool {
+	blocked, err := dbUtils.IsBlocked(otherUserID, userID)
+	if err != nil {
+		logger.Error("查询屏蔽列表失败:", err)
+		return false
+	}
+	return blocked
+}
+
+// handleBlockUser 屏蔽用户，被屏蔽的用户无法向自己发送私聊消息、查看在线状态、发送好友申请和邀请入群
+func handleBlockUser(session *Session, request interface{}) {
+	req := request.(*jsonprovider.BlockUserRequest)
+	userID := session.User.UserId
+
+	if req.UserID == userID {
+		sendBlockUserResponse(session, configData.Commands.BlockUser, req.UserID, "不能屏蔽自己")
+		return
+	}
+	if _, err := dbUtils.GetUserFromDB(req.UserID); err != nil {
+		sendBlockUserResponse(session, configData.Commands.BlockUser, req.UserID, errUserNotExist.Error())
+		return
+	}
+	err := dbUtils.BlockUser(userID, req.UserID)
+	if err != nil {
+		logger.Error("屏蔽用户失败:", err)
+		sendBlockUserResponse(session, configData.Commands.BlockUser, req.UserID, "操作失败")
+		return
+	}
+	sendBlockUserResponse(session, configData.Commands.BlockUser, req.UserID, "")
+}
+
+// handleUnblockUser 取消屏蔽用户
+func handleUnblockUser(session *Session, request interface{}) {
+	req := request.(*jsonprovider.BlockUserRequest)
+	userID := session.User.UserId
+
+	err := dbUtils.UnblockUser(userID, req.UserID)
+	if err != nil {
+		logger.Error("取消屏蔽用户失败:", err)
+		sendBlockUserResponse(session, configData.Commands.UnblockUser, req.UserID, "操作失败")
+		return
+	}
+	sendBlockUserResponse(session, configData.Commands.UnblockUser, req.UserID, "")
+}
+
+// sendBlockUserResponse message 为空表示操作成功，成功时推送给用户的所有在线会话
+func sendBlockUserResponse(session *Session, command string, targetID int, message string) {
+	res := jsonprovider.BlockUserResponse{
+		UserID:  targetID,
+		Success: message == "",
+		Message: message,
+	}
+	payload := jsonprovider.SdandarlizeJSON_byte(command, res)
+	var err error
+	if res.Success {
+		_, err = sendMessageToUser(session.User.UserId, payload)
+	} else {
+		err = session.send(payload)
+	}
+	if err != nil {
+		logger.Error("屏蔽操作结果回发失败:", err)
+	}
+}
+
+func handleGetBlockList(session *Session, _ interface{}) {
+	blockList, err := dbUtils.GetBlockList(session.User.UserId)
+	if err != nil {
+		logger.Error("获取屏蔽列表失败:", err)
+		sendErrorResponse(session, configData.Commands.GetBlockList, "获取屏蔽列表失败")
+		return
+	}
+	res := jsonprovider.GetBlockListResponse{
+		BlockList: blockList,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetBlockList, res))
+	if err != nil {
+		logger.Error("屏蔽列表回发失败:", err)
+	}
+}
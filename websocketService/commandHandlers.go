@@ -0,0 +1,558 @@
+package websocketService
+
+import (
+	"config"
+	"database/sql"
+	"dbUtils"
+	"encoding/json"
+	"errors"
+	jsonprovider "jsonProvider"
+	"logger"
+	"time"
+)
+
+// registerBuiltinCommands 按配置文件中的命令名注册内置命令
+func registerBuiltinCommands() {
+	RegisterCommand(configData.Commands.Heart, config.PermissionBannedUser, nil, handleHeart)
+	RegisterCommand(configData.Commands.Logout, config.PermissionBannedUser, nil, handleLogout)
+	RegisterCommand(configData.Commands.CheckUserOnlineState, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.CheckUserOnlineStateRequest) }, handleCheckUserOnlineState)
+	RegisterCommand(configData.Commands.SetPresence, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.SetPresenceRequest) }, handleSetPresence)
+	RegisterCommand(configData.Commands.GetPresence, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GetPresenceRequest) }, handleGetPresence)
+	RegisterCommand(configData.Commands.SendUserMessage, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.SendMessageRequest) }, handleSendUserMessage)
+	RegisterCommand(configData.Commands.AckMessage, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.SendMessagePackResponseFromUser) }, handleAckMessage)
+	RegisterCommand(configData.Commands.SendGroupMessage, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.SendGroupMessageRequest) }, handleSendGroupMessage)
+	RegisterCommand(configData.Commands.RecallMessage, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.RecallMessageRequest) }, handleRecallMessage)
+	RegisterCommand(configData.Commands.EditMessage, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.EditMessageRequest) }, handleEditMessage)
+	RegisterCommand(configData.Commands.GetMessageEdits, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GetMessageEditsRequest) }, handleGetMessageEdits)
+	RegisterCommand(configData.Commands.GetThreadMessages, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GetThreadMessagesRequest) }, handleGetThreadMessages)
+	RegisterCommand(configData.Commands.AddReaction, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.ReactionRequest) }, handleAddReaction)
+	RegisterCommand(configData.Commands.RemoveReaction, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.ReactionRequest) }, handleRemoveReaction)
+	RegisterCommand(configData.Commands.GetUnreadMentions, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GetUnreadMentionsRequest) }, handleGetUnreadMentions)
+	RegisterCommand(configData.Commands.MarkMentionsRead, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.MarkMentionsReadRequest) }, handleMarkMentionsRead)
+	RegisterCommand(configData.Commands.MarkRead, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.MarkReadRequest) }, handleMarkRead)
+	RegisterCommand(configData.Commands.GetConversations, config.PermissionOrdinaryUser, nil, handleGetConversations)
+	RegisterCommand(configData.Commands.SendSignal, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.SendSignalRequest) }, handleSendSignal)
+	RegisterCommand(configData.Commands.AddFriend, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.AddFriendRequest) }, handleAddFriend)
+	RegisterCommand(configData.Commands.DeleteFriend, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.DeleteFriendRequest) }, handleDeleteFriend)
+	RegisterCommand(configData.Commands.ChangeFriendSettings, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.ChangeFriendSettingsRequest) }, handleChangeFriendSettings)
+	RegisterCommand(configData.Commands.BlockUser, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.BlockUserRequest) }, handleBlockUser)
+	RegisterCommand(configData.Commands.UnblockUser, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.BlockUserRequest) }, handleUnblockUser)
+	RegisterCommand(configData.Commands.GetBlockList, config.PermissionOrdinaryUser, nil, handleGetBlockList)
+	RegisterCommand(configData.Commands.GetFriendRequests, config.PermissionOrdinaryUser, nil, handleGetFriendRequests)
+	RegisterCommand(configData.Commands.AcceptFriendRequest, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.FriendRequestActionRequest) }, handleAcceptFriendRequest)
+	RegisterCommand(configData.Commands.RejectFriendRequest, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.FriendRequestActionRequest) }, handleRejectFriendRequest)
+	RegisterCommand(configData.Commands.CancelFriendRequest, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.FriendRequestActionRequest) }, handleCancelFriendRequest)
+	RegisterCommand(configData.Commands.CreateGroup, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.CreateGroupRequest) }, handleCreateGroup)
+	RegisterCommand(configData.Commands.BreakGroup, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.BreakGroupRequest) }, handleBreakGroup)
+	RegisterCommand(configData.Commands.InviteGroupMember, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GroupMemberRequest) }, handleInviteGroupMember)
+	RegisterCommand(configData.Commands.AcceptGroupInvite, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GroupInviteActionRequest) }, handleAcceptGroupInvite)
+	RegisterCommand(configData.Commands.DeclineGroupInvite, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GroupInviteActionRequest) }, handleDeclineGroupInvite)
+	RegisterCommand(configData.Commands.GetGroupInvites, config.PermissionOrdinaryUser, nil, handleGetGroupInvites)
+	RegisterCommand(configData.Commands.JoinGroup, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GroupRequest) }, handleJoinGroup)
+	RegisterCommand(configData.Commands.LeaveGroup, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GroupRequest) }, handleLeaveGroup)
+	RegisterCommand(configData.Commands.KickGroupMember, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GroupMemberRequest) }, handleKickGroupMember)
+	RegisterCommand(configData.Commands.TransferGroup, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GroupMemberRequest) }, handleTransferGroup)
+	RegisterCommand(configData.Commands.SetGroupMemberRole, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.SetGroupMemberRoleRequest) }, handleSetGroupMemberRole)
+	RegisterCommand(configData.Commands.MuteGroupMember, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.MuteGroupMemberRequest) }, handleMuteGroupMember)
+	RegisterCommand(configData.Commands.ChangeGroupSettings, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.ChangeGroupSettingsRequest) }, handleChangeGroupSettings)
+	RegisterCommand(configData.Commands.GetGroupMessages, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GetGroupMessagesRequest) }, handleGetGroupMessages)
+	RegisterCommand(configData.Commands.GetUserData, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GetUserDataRequest) }, handleGetUserData)
+	RegisterCommand(configData.Commands.GetOfflineMessage, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GetOfflineMessagesRequest) }, handleGetOfflineMessage)
+	RegisterCommand(configData.Commands.Sync, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.SyncRequest) }, handleSync)
+	RegisterCommand(configData.Commands.GetMessagesWithUser, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.GetMessagesWithUserRequest) }, handleGetMessagesWithUser)
+	RegisterCommand(configData.Commands.ChangeAvatar, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.ChangeAvatarRequest) }, handleChangeAvatar)
+	RegisterCommand(configData.Commands.ChangeSettings, config.PermissionOrdinaryUser,
+		func() interface{} { return new(jsonprovider.ChangeSettingsRequest) }, handleChangeSettings)
+}
+
+func handleHeart(session *Session, _ interface{}) {
+	// 只有登录时声明使用应用层心跳包的客户端才回发心跳包，其余连接依赖ping/pong
+	if !session.artificialHeartbeat {
+		return
+	}
+	responsePack := jsonprovider.SdandarlizeJSON_byte(configData.Commands.Heart, &jsonprovider.HeartBeatPack{
+		TimeStamp: time.Now().Local().UTC().Nanosecond(),
+	})
+	// 发送响应给请求者
+	err := session.send(responsePack)
+	if err != nil {
+		logger.Error("心跳包回发错误:", err)
+	}
+}
+
+func handleLogout(session *Session, _ interface{}) {
+	// 关闭连接后读取循环退出，由 HandleWebSocket 负责清理
+	session.Close()
+}
+
+func handleCheckUserOnlineState(session *Session, request interface{}) {
+	onlineStateRequest := request.(*jsonprovider.CheckUserOnlineStateRequest)
+
+	// 检查用户各设备的在线状态，对方设置不允许查看或已屏蔽自己时视为离线
+	sessions := GetSessions(onlineStateRequest.UserID)
+	if len(sessions) > 0 && (!canSeeOnlineStatus(sessions[0].User, session.User.UserId) || isBlockedBy(session.User.UserId, onlineStateRequest.UserID)) {
+		sessions = nil
+	}
+	devices := make([]jsonprovider.OnlineDevice, 0, len(sessions))
+	for _, target := range sessions {
+		devices = append(devices, jsonprovider.OnlineDevice{
+			DeviceID:    target.DeviceID,
+			Platform:    target.Platform,
+			ConnectTime: target.ConnectTime.UnixNano(),
+		})
+	}
+
+	status := jsonprovider.PresenceOffline
+	if len(sessions) > 0 {
+		status = sessions[0].User.Presence()
+	}
+
+	// 构造响应
+	onlineStateResponse := jsonprovider.CheckUserOnlineStateResponse{
+		UserID:   onlineStateRequest.UserID,
+		IsOnline: len(devices) > 0,
+		Status:   status,
+		Devices:  devices,
+	}
+
+	// 序列化响应为JSON
+	responseJSON := jsonprovider.SdandarlizeJSON_byte(configData.Commands.CheckUserOnlineState, onlineStateResponse)
+
+	// 发送响应给请求者
+	err := session.send(responseJSON)
+	if err != nil {
+		logger.Error("Failed to send message:", err)
+	}
+}
+
+func handleSendUserMessage(session *Session, request interface{}) {
+	userID := session.User.UserId
+	var state int
+	//获取基本信息
+	receivedPack := request.(*jsonprovider.SendMessageRequest)
+	recipientID := receivedPack.TargetID
+	messageContent := receivedPack.MessageBody
+	requestMessageID := receivedPack.RequestID
+	timeStamp := int(time.Now().UnixNano())
+	// 被接收方屏蔽或接收方的私聊消息策略不允许时不保存也不投递
+	if !canSendDirectMessage(userID, recipientID) {
+		logger.Debug("用户", userID, "无权向", recipientID, "发送私聊消息，拒绝投递")
+		refuseUserMessage(session, requestMessageID, jsonprovider.UserRefused)
+		return
+	}
+	replyTo, threadRoot, err := resolveMessageReference(userID, recipientID, 0, false, receivedPack.ReplyTo, receivedPack.ThreadRoot)
+	if err != nil {
+		refuseUserMessage(session, requestMessageID, messageReferenceRefusal(err))
+		return
+	}
+	kind, content, messageType, err := resolveMessageContent(session.User, receivedPack.Kind, messageContent, receivedPack.Content)
+	if err != nil {
+		refuseUserMessage(session, requestMessageID, messageContentRefusal(err))
+		return
+	}
+	//保存到数据库，获取消息ID
+	messageID, err := dbUtils.SaveMessageToDB(&jsonprovider.Message{
+		SenderID:    userID,
+		ReceiverID:  recipientID,
+		MessageBody: messageContent,
+		MessageType: messageType,
+		ReplyTo:     replyTo,
+		ThreadRoot:  threadRoot,
+		Kind:        kind,
+		Content:     content,
+	})
+	if err != nil {
+		logger.Error("用户", recipientID, "发送信息时数据库插入失败")
+		return
+	}
+	//构造发送数据包
+	sendingPack := &jsonprovider.SendMessageToTargetPack{
+		SenderID:    userID,
+		MessageID:   messageID,
+		MessageBody: messageContent,
+		TimeStamp:   timeStamp,
+		ReplyTo:     replyTo,
+		ThreadRoot:  threadRoot,
+		Kind:        kind,
+		Content:     content,
+	}
+	// 向指定用户发送消息
+	payload := jsonprovider.SdandarlizeJSON_byte(configData.Commands.SendUserMessage, sendingPack)
+	isSent, msgerr := sendMessageToUser(recipientID, payload)
+	if !isSent {
+		if msgerr == nil {
+			logger.Info("用户", recipientID, "不在线，已保存到离线消息")
+			state = jsonprovider.UserIsNotOnline
+		} else {
+			state = jsonprovider.ServerSendError
+		}
+	} else {
+		// 等待接收方ACK，未确认时重发
+		trackMessage(messageID, userID, recipientID, payload)
+		state = jsonprovider.MessageSent
+	}
+	//回发ACK包
+	ACKPack := &jsonprovider.SendMessageResponse{
+		RequestID: requestMessageID,
+		MessageID: messageID,
+		TimeStamp: timeStamp,
+		State:     state,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.SendUserMessage, ACKPack))
+	if err != nil {
+		logger.Debug("ACK回发错误", err)
+	}
+}
+
+// refuseUserMessage 拒绝发送私聊消息，消息不会保存
+func refuseUserMessage(session *Session, requestID int, state int) {
+	refusedPack := &jsonprovider.SendMessageResponse{
+		RequestID: requestID,
+		TimeStamp: int(time.Now().UnixNano()),
+		State:     state,
+	}
+	err := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.SendUserMessage, refusedPack))
+	if err != nil {
+		logger.Debug("ACK回发错误", err)
+	}
+}
+
+func handleSendGroupMessage(session *Session, request interface{}) {
+	userID := session.User.UserId
+	req := request.(*jsonprovider.SendGroupMessageRequest)
+
+	// 获取群成员
+	membership, err := dbUtils.GetGroupMembership(int(req.GroupID))
+	if err != nil {
+		logger.Error("Failed to get group members:", err)
+		return
+	}
+	groupMembers := membership.Members
+
+	settings, err := dbUtils.GetGroupSettings(int(req.GroupID))
+	if err != nil {
+		logger.Error("Failed to get group settings:", err)
+		return
+	}
+
+	// 非群成员、角色没有发言权限或被禁言时拒绝发送，全员禁言时只有拥有禁言权限的成员可以发言
+	muted := membership.IsMuted(userID, time.Now()) || (settings.MuteAll && !hasGroupPermission(membership, userID, groupPermissionMute))
+	if !hasGroupPermission(membership, userID, groupPermissionSend) || muted {
+		logger.Debug("用户", userID, "无权在群", req.GroupID, "中发言")
+		refuseGroupMessage(session, req.RequestID, jsonprovider.UserRefused)
+		return
+	}
+	onlyReceived := settings.HistoryVisibility != jsonprovider.GroupHistoryAll
+	replyTo, threadRoot, err := resolveMessageReference(userID, 0, int(req.GroupID), onlyReceived, req.ReplyTo, req.ThreadRoot)
+	if err != nil {
+		refuseGroupMessage(session, req.RequestID, messageReferenceRefusal(err))
+		return
+	}
+	// @全体成员需要对应权限，被@的用户必须是群成员
+	if req.MentionAll && !hasGroupPermission(membership, userID, groupPermissionMentionAll) {
+		refuseGroupMessage(session, req.RequestID, jsonprovider.UserRefused)
+		return
+	}
+	mentions, ok := resolveMentions(membership, userID, req.Mentions)
+	if !ok {
+		refuseGroupMessage(session, req.RequestID, jsonprovider.MessageInvalid)
+		return
+	}
+	kind, content, messageType, err := resolveMessageContent(session.User, req.Kind, req.MessageBody, req.Content)
+	if err != nil {
+		refuseGroupMessage(session, req.RequestID, messageContentRefusal(err))
+		return
+	}
+
+	// 保存消息到数据库
+	timeStamp := int(time.Now().UnixNano())
+	messageID, err := dbUtils.SaveGroupMessageToDB(&jsonprovider.Message{
+		SenderID:    userID,
+		GroupID:     int(req.GroupID),
+		MessageBody: req.MessageBody,
+		MessageType: messageType,
+		ReplyTo:     replyTo,
+		ThreadRoot:  threadRoot,
+		Kind:        kind,
+		Content:     content,
+		Mentions:    mentions,
+		MentionAll:  req.MentionAll,
+	}, groupMembers)
+	if err != nil {
+		logger.Error("用户发送群消息时数据库插入失败")
+		return
+	}
+
+	// 构造发送数据包
+	sendingPack := &jsonprovider.SendMessageToGroupPack{
+		SenderID:    userID,
+		MessageID:   messageID,
+		MessageBody: req.MessageBody,
+		TimeStamp:   timeStamp,
+		ReplyTo:     replyTo,
+		ThreadRoot:  threadRoot,
+		Kind:        kind,
+		Content:     content,
+		Mentions:    mentions,
+		MentionAll:  req.MentionAll,
+	}
+	payload := jsonprovider.SdandarlizeJSON_byte(configData.Commands.SendGroupMessage, sendingPack)
+	sendingPack.Mentioned = true
+	mentionedPayload := jsonprovider.SdandarlizeJSON_byte(configData.Commands.SendGroupMessage, sendingPack)
+
+	// 向所有群成员发送消息，被@的成员收到的数据包 mentioned 为true
+	// 成功推送给在线成员即视为已送达，不再作为离线消息下发
+	delivered := make([]int, 0, len(groupMembers))
+	for _, memberID := range groupMembers {
+		message := payload
+		if memberID != userID && (req.MentionAll || dbUtils.ContainsMember(mentions, memberID)) {
+			message = mentionedPayload
+		}
+		sent, err := sendMessageToUser(memberID, message)
+		if err != nil {
+			logger.Debug("群消息发送错误", err)
+		}
+		if sent && memberID != userID {
+			delivered = append(delivered, memberID)
+		}
+	}
+	err = dbUtils.MarkMessageDeliveredTo(messageID, delivered)
+	if err != nil {
+		logger.Error("更新群消息投递状态失败:", err)
+	}
+
+	//回发ACK包
+	ACKPack := &jsonprovider.SendGroupMessageResponse{
+		RequestID: req.RequestID,
+		MessageID: messageID,
+		TimeStamp: timeStamp,
+		State:     jsonprovider.UserReceived,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.SendGroupMessage, ACKPack))
+	if err != nil {
+		logger.Debug("群消息ACK回发错误", err)
+	}
+}
+
+// refuseGroupMessage 拒绝发送群消息，消息不会保存
+func refuseGroupMessage(session *Session, requestID int, state int) {
+	refusedPack := &jsonprovider.SendGroupMessageResponse{
+		RequestID: requestID,
+		TimeStamp: int(time.Now().UnixNano()),
+		State:     state,
+	}
+	err := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.SendGroupMessage, refusedPack))
+	if err != nil {
+		logger.Debug("群消息ACK回发错误", err)
+	}
+}
+
+func handleCreateGroup(session *Session, request interface{}) {
+	userID := session.User.UserId
+	req := request.(*jsonprovider.CreateGroupRequest)
+
+	if !validGroupText(req.GroupName, true, maxGroupNameLength) || !validGroupText(req.GroupExplaination, false, maxGroupExplanationLength) {
+		err := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.CreateGroup, jsonprovider.CreateGroupResponse{}))
+		if err != nil {
+			logger.Error("Failed to send group creation response:", err)
+		}
+		return
+	}
+
+	// 在数据库中创建新的群聊
+	res, err := db.Exec("INSERT INTO groupdatatable (groupName, groupExplaination, groupMaster) VALUES (?, ?, ?)", req.GroupName, req.GroupExplaination, userID)
+	if err != nil {
+		logger.Error("Failed to create group:", err)
+		return
+	}
+
+	// 获取新群聊的ID
+	groupID, err := res.LastInsertId()
+	if err != nil {
+		logger.Error("Failed to get group ID:", err)
+		return
+	}
+
+	// 创建新的群聊成员列表
+	groupMembers := []int{userID}
+
+	// 更新群聊的成员列表
+	groupMembersJSON, _ := json.Marshal(groupMembers)
+	_, err = db.Exec("UPDATE groupdatatable SET groupMembers = ? WHERE groupID = ?", groupMembersJSON, groupID)
+	if err != nil {
+		logger.Error("Failed to update group members:", err)
+	}
+
+	// 创建响应
+	responsePack := jsonprovider.CreateGroupResponse{
+		GroupID: groupID,
+		Success: err == nil,
+	}
+
+	// 发送响应
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.CreateGroup, responsePack)
+	err = session.send(message)
+	if err != nil {
+		logger.Error("Failed to send group creation response:", err)
+	}
+}
+
+// handleBreakGroup 群主解散群聊，成功后向所有在线群成员推送 groupEvent
+func handleBreakGroup(session *Session, request interface{}) {
+	userID := session.User.UserId
+	req := request.(*jsonprovider.BreakGroupRequest)
+
+	// 在数据库中删除群聊，群聊不存在或不是群主时不会删除任何记录
+	members, err := dbUtils.BreakGroup(int(req.GroupID), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 区分群聊不存在与不是群主
+		if _, getErr := dbUtils.GetGroupMembership(int(req.GroupID)); getErr == nil {
+			err = errNotGroupMaster
+		}
+	}
+
+	// 创建响应
+	res := jsonprovider.BreakGroupResponse{
+		GroupID: req.GroupID,
+		Success: err == nil,
+	}
+	if err != nil {
+		res.Message = groupErrorMessage(err)
+	}
+
+	// 发送响应
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.BreakGroup, res)
+	sendErr := session.send(message)
+	if sendErr != nil {
+		logger.Error("Failed to send group break response:", sendErr)
+	}
+	if err != nil {
+		return
+	}
+
+	pushGroupEvent(members, jsonprovider.GroupMemberEvent{
+		GroupID:    req.GroupID,
+		Event:      jsonprovider.GroupEventBreak,
+		OperatorID: userID,
+		TimeStamp:  int(time.Now().UnixNano()),
+	})
+}
+
+func handleGetUserData(session *Session, _ interface{}) {
+	userID := session.User.UserId
+
+	// 从数据库中获取用户数据
+	res, err := dbUtils.GetUserFromDB(userID)
+	if err != nil {
+		logger.Error("Failed to get user data:", err)
+		return
+	}
+
+	// 发送响应
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetUserData, res)
+	err = session.send(message)
+	if err != nil {
+		logger.Error("Failed to send user data:", err)
+	}
+}
+
+func handleGetOfflineMessage(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GetOfflineMessagesRequest)
+	handleGetOfflineMessages(session, req.Limit)
+}
+
+func handleGetMessagesWithUser(session *Session, request interface{}) {
+	userID := session.User.UserId
+	req := request.(*jsonprovider.GetMessagesWithUserRequest)
+
+	// 从数据库中查询聊天记录
+	messages, err := dbUtils.GetMessagesBetweenUsers(userID, req.OtherUserID, req.StartTime, req.EndTime, historyMessageTypes)
+	if err != nil {
+		logger.Error("Failed to get messages:", err)
+		return
+	}
+
+	// 创建响应
+	res := jsonprovider.GetMessagesWithUserResponse{
+		UserID:   userID,
+		Messages: messages,
+	}
+
+	// 发送响应
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetMessagesWithUser, res)
+	err = session.send(message)
+	if err != nil {
+		logger.Error("Failed to send message history:", err)
+	}
+}
+
+func handleChangeAvatar(session *Session, request interface{}) {
+	user := session.User
+	userID := user.UserId
+	req := request.(*jsonprovider.ChangeAvatarRequest)
+
+	// 更新用户结构体
+	user.UserAvatar = req.NewAvatar
+
+	// 更新数据库
+	_, err := db.Exec("UPDATE userdatatable SET userAvatar = ? WHERE userID = ?", req.NewAvatar, userID)
+	if err != nil {
+		logger.Error("Failed to update avatar:", err)
+	}
+
+	// 创建响应
+	res := jsonprovider.ChangeAvatarResponse{
+		UserID:    userID,
+		NewAvatar: req.NewAvatar,
+		Success:   err == nil,
+	}
+
+	// 发送响应
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.ChangeAvatar, res)
+	err = session.send(message)
+	if err != nil {
+		logger.Error("Failed to send avatar change response:", err)
+	}
+}
@@ -0,0 +1,394 @@
+package websocketService
+
+import (
+	"database/sql"
+	"dbUtils"
+	"encoding/json"
+	"errors"
+	jsonprovider "jsonProvider"
+	"logger"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxFriendRequestMessageLength = 255
+	maxFriendRemarkLength         = 64
+	maxFriendGroupLength          = 32
+)
+
+var (
+	errCannotAddSelf          = errors.New("不能添加自己为好友")
+	errAlreadyFriend          = errors.New("已是好友")
+	errFriendRequestRefused   = errors.New("对方不允许添加好友")
+	errFriendRequestPending   = errors.New("已发送过好友申请，请等待对方处理")
+	errFriendRequestHandled   = errors.New("好友申请已处理")
+	errFriendRequestExpired   = errors.New("好友申请已过期")
+	errFriendRequestTooLong   = errors.New("验证消息过长")
+	errFriendRequestForbidden = errors.New("无权处理该好友申请")
+	errNotFriend              = errors.New("对方不是好友")
+	errBlockedUser            = errors.New("已屏蔽对方，请先取消屏蔽")
+	errInvalidFriendSettings  = errors.New("备注或分组名称过长")
+)
+
+// friendErrorMessage 将好友操作的错误转换为返回给客户端的提示
+func friendErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "好友申请不存在"
+	case errors.Is(err, errCannotAddSelf), errors.Is(err, errAlreadyFriend), errors.Is(err, errFriendRequestRefused),
+		errors.Is(err, errFriendRequestPending), errors.Is(err, errFriendRequestHandled), errors.Is(err, errFriendRequestExpired),
+		errors.Is(err, errFriendRequestTooLong), errors.Is(err, errFriendRequestForbidden), errors.Is(err, errNotFriend), errors.Is(err, errBlockedUser),
+		errors.Is(err, errInvalidFriendSettings), errors.Is(err, errUserNotExist):
+		return err.Error()
+	default:
+		logger.Error("好友操作失败:", err)
+		return "操作失败"
+	}
+}
+
+// friendRequestExpireBefore 创建时间早于该时间的待处理好友申请视为已过期
+func friendRequestExpireBefore() int64 {
+	return time.Now().Add(-time.Duration(configData.FriendRequestExpireHours) * time.Hour).UnixNano()
+}
+
+// handleAddFriend 向对方发送好友申请，对方已向自己发送过申请时直接成为好友
+func handleAddFriend(session *Session, request interface{}) {
+	req := request.(*jsonprovider.AddFriendRequest)
+	userID := session.User.UserId
+
+	friendRequest, err := sendFriendRequest(userID, req.FriendID, req.Message)
+	res := jsonprovider.AddFriendResponse{
+		UserID:   userID,
+		FriendID: req.FriendID,
+		Success:  err == nil,
+	}
+	if err != nil {
+		res.Message = friendErrorMessage(err)
+	} else {
+		res.RequestID = friendRequest.RequestID
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.AddFriend, res))
+	if sendErr != nil {
+		logger.Error("Failed to send add friend response:", sendErr)
+	}
+	if err == nil {
+		pushFriendRequestEvent(friendRequest)
+	}
+}
+
+func sendFriendRequest(userID int, targetID int, message string) (*jsonprovider.FriendRequest, error) {
+	if userID == targetID {
+		return nil, errCannotAddSelf
+	}
+	if utf8.RuneCountInString(message) > maxFriendRequestMessageLength {
+		return nil, errFriendRequestTooLong
+	}
+	if _, err := dbUtils.GetUserFromDB(targetID); err != nil {
+		return nil, errUserNotExist
+	}
+	friends, err := dbUtils.GetFriendList(userID)
+	if err != nil {
+		return nil, err
+	}
+	if friends.Contains(targetID) {
+		return nil, errAlreadyFriend
+	}
+
+	// 任意一方屏蔽了对方时不能发送申请，也不能通过对方的旧申请成为好友
+	if isBlockedBy(userID, targetID) {
+		return nil, errFriendRequestRefused
+	}
+	if isBlockedBy(targetID, userID) {
+		return nil, errBlockedUser
+	}
+
+	expireBefore := friendRequestExpireBefore()
+	// 对方已向自己发送过申请时视为接受对方的申请
+	reverse, err := dbUtils.FindPendingFriendRequest(targetID, userID, expireBefore)
+	if err == nil {
+		err = acceptFriendRequest(reverse)
+		return reverse, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	_, err = dbUtils.FindPendingFriendRequest(userID, targetID, expireBefore)
+	if err == nil {
+		return nil, errFriendRequestPending
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	allowed, err := canAddFriend(userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errFriendRequestRefused
+	}
+
+	requestID, err := dbUtils.CreateFriendRequest(userID, targetID, message)
+	if err != nil {
+		return nil, err
+	}
+	return dbUtils.GetFriendRequest(requestID)
+}
+
+// canAddFriend 根据对方的“谁可以添加我”设置判断能否向对方发送好友申请
+func canAddFriend(userID int, targetID int) (bool, error) {
+	settings, err := loadUserSettings(targetID)
+	if err != nil {
+		return false, err
+	}
+	switch settings.WhoCanAddMe {
+	case jsonprovider.AddMeNobody:
+		return false, nil
+	case jsonprovider.AddMeGroupMembers:
+		return dbUtils.ShareGroup(userID, targetID)
+	default:
+		return true, nil
+	}
+}
+
+func acceptFriendRequest(friendRequest *jsonprovider.FriendRequest) error {
+	err := dbUtils.AcceptFriendRequest(friendRequest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errFriendRequestHandled
+	}
+	if err != nil {
+		return err
+	}
+	friendRequest.State = jsonprovider.FriendRequestAccepted
+	friendRequest.UpdateTime = time.Now().UnixNano()
+	refreshFriendList(friendRequest.SenderID)
+	refreshFriendList(friendRequest.ReceiverID)
+	return nil
+}
+
+func handleAcceptFriendRequest(session *Session, request interface{}) {
+	handleFriendRequestAction(session, configData.Commands.AcceptFriendRequest, request.(*jsonprovider.FriendRequestActionRequest),
+		func(friendRequest *jsonprovider.FriendRequest) error {
+			if friendRequest.ReceiverID != session.User.UserId {
+				return errFriendRequestForbidden
+			}
+			return acceptFriendRequest(friendRequest)
+		})
+}
+
+func handleRejectFriendRequest(session *Session, request interface{}) {
+	handleFriendRequestAction(session, configData.Commands.RejectFriendRequest, request.(*jsonprovider.FriendRequestActionRequest),
+		func(friendRequest *jsonprovider.FriendRequest) error {
+			if friendRequest.ReceiverID != session.User.UserId {
+				return errFriendRequestForbidden
+			}
+			return changeFriendRequestState(friendRequest, jsonprovider.FriendRequestRejected)
+		})
+}
+
+func handleCancelFriendRequest(session *Session, request interface{}) {
+	handleFriendRequestAction(session, configData.Commands.CancelFriendRequest, request.(*jsonprovider.FriendRequestActionRequest),
+		func(friendRequest *jsonprovider.FriendRequest) error {
+			if friendRequest.SenderID != session.User.UserId {
+				return errFriendRequestForbidden
+			}
+			return changeFriendRequestState(friendRequest, jsonprovider.FriendRequestCancelled)
+		})
+}
+
+// handleFriendRequestAction 检查好友申请是否仍待处理后执行apply，成功时向双方推送申请状态
+func handleFriendRequestAction(session *Session, command string, req *jsonprovider.FriendRequestActionRequest, apply func(friendRequest *jsonprovider.FriendRequest) error) {
+	friendRequest, err := dbUtils.GetFriendRequest(req.RequestID)
+	if err == nil {
+		userID := session.User.UserId
+		if friendRequest.SenderID != userID && friendRequest.ReceiverID != userID {
+			// 不暴露与自己无关的申请
+			err = sql.ErrNoRows
+		} else if friendRequest.State != jsonprovider.FriendRequestPending {
+			err = errFriendRequestHandled
+			if friendRequest.State == jsonprovider.FriendRequestExpired {
+				err = errFriendRequestExpired
+			}
+		} else if friendRequest.CreateTime < friendRequestExpireBefore() {
+			err = changeFriendRequestState(friendRequest, jsonprovider.FriendRequestExpired)
+			if err == nil {
+				pushFriendRequestEvent(friendRequest)
+				err = errFriendRequestExpired
+			}
+		} else {
+			err = apply(friendRequest)
+		}
+	}
+
+	res := jsonprovider.FriendRequestActionResponse{
+		RequestID: req.RequestID,
+		Success:   err == nil,
+	}
+	if err != nil {
+		res.Message = friendErrorMessage(err)
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(command, res))
+	if sendErr != nil {
+		logger.Error("好友申请处理结果回发失败:", sendErr)
+	}
+	if err == nil {
+		pushFriendRequestEvent(friendRequest)
+	}
+}
+
+func changeFriendRequestState(friendRequest *jsonprovider.FriendRequest, state int) error {
+	err := dbUtils.UpdateFriendRequestState(friendRequest.RequestID, state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errFriendRequestHandled
+	}
+	if err != nil {
+		return err
+	}
+	friendRequest.State = state
+	friendRequest.UpdateTime = time.Now().UnixNano()
+	return nil
+}
+
+// handleGetFriendRequests 获取待处理的好友申请，离线期间收到的申请通过此命令获取
+func handleGetFriendRequests(session *Session, _ interface{}) {
+	userID := session.User.UserId
+
+	expireBefore := friendRequestExpireBefore()
+	err := dbUtils.ExpireFriendRequests(expireBefore)
+	if err != nil {
+		logger.Error("更新过期好友申请失败:", err)
+	}
+	incoming, outgoing, err := dbUtils.GetPendingFriendRequests(userID, expireBefore)
+	if err != nil {
+		logger.Error("获取好友申请失败:", err)
+		sendErrorResponse(session, configData.Commands.GetFriendRequests, "获取好友申请失败")
+		return
+	}
+
+	res := jsonprovider.GetFriendRequestsResponse{
+		Incoming: incoming,
+		Outgoing: outgoing,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetFriendRequests, res))
+	if err != nil {
+		logger.Error("好友申请回发失败:", err)
+	}
+}
+
+// pushFriendRequestEvent 向申请双方的在线会话推送好友申请
+func pushFriendRequestEvent(friendRequest *jsonprovider.FriendRequest) {
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.FriendRequestEvent, friendRequest)
+	for _, userID := range []int{friendRequest.SenderID, friendRequest.ReceiverID} {
+		_, err := sendMessageToUser(userID, message)
+		if err != nil {
+			logger.Debug("好友申请推送失败", err)
+		}
+	}
+}
+
+// handleDeleteFriend 解除双方的好友关系
+func handleDeleteFriend(session *Session, request interface{}) {
+	userID := session.User.UserId
+	req := request.(*jsonprovider.DeleteFriendRequest)
+
+	err := dbUtils.RemoveFriendship(userID, req.FriendID)
+	if err != nil {
+		logger.Error("Failed to update friend list:", err)
+	} else {
+		refreshFriendList(userID)
+		refreshFriendList(req.FriendID)
+	}
+
+	// 创建响应
+	res := jsonprovider.DeleteFriendResponse{
+		UserID:   userID,
+		FriendID: req.FriendID,
+		Success:  err == nil,
+	}
+
+	// 发送响应
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.DeleteFriend, res)
+	err = session.send(message)
+	if err != nil {
+		logger.Error("Failed to send delete friend response:", err)
+	}
+}
+
+// handleChangeFriendSettings 修改备注、分组、免打扰和置顶，成功后同步到用户的所有在线会话
+func handleChangeFriendSettings(session *Session, request interface{}) {
+	req := request.(*jsonprovider.ChangeFriendSettingsRequest)
+	userID := session.User.UserId
+
+	friend, err := dbUtils.UpdateFriendSettings(userID, req.FriendID, func(friend *jsonprovider.Friend) error {
+		if req.Remark != nil {
+			if utf8.RuneCountInString(*req.Remark) > maxFriendRemarkLength {
+				return errInvalidFriendSettings
+			}
+			friend.Remark = *req.Remark
+		}
+		if req.Group != nil {
+			if utf8.RuneCountInString(*req.Group) > maxFriendGroupLength {
+				return errInvalidFriendSettings
+			}
+			friend.Group = *req.Group
+		}
+		if req.Muted != nil {
+			friend.Muted = *req.Muted
+		}
+		if req.Pinned != nil {
+			friend.Pinned = *req.Pinned
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errNotFriend
+	}
+
+	res := jsonprovider.ChangeFriendSettingsResponse{
+		FriendID: req.FriendID,
+		Success:  err == nil,
+	}
+	if err != nil {
+		res.Message = friendErrorMessage(err)
+		sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.ChangeFriendSettings, res))
+		if sendErr != nil {
+			logger.Error("修改好友设置结果回发失败:", sendErr)
+		}
+		return
+	}
+
+	refreshFriendList(userID)
+	res.Friend = friend
+	_, err = sendMessageToUser(userID, jsonprovider.SdandarlizeJSON_byte(configData.Commands.ChangeFriendSettings, res))
+	if err != nil {
+		logger.Error("修改好友设置结果回发失败:", err)
+	}
+}
+
+// refreshFriendList 好友关系变化后更新在线用户内存中的好友列表
+func refreshFriendList(userID int) {
+	user := getOnlineUser(userID)
+	if user == nil {
+		return
+	}
+	friends, err := dbUtils.GetFriendList(userID)
+	if err != nil {
+		logger.Error("读取好友列表失败:", err)
+		return
+	}
+	user.setFriendList(jsonprovider.StringifyJSON(friends))
+}
+
+// FriendList 返回在线用户内存中的好友列表，好友关系变化时可能被其他会话的协程更新
+func (user *User) FriendList() json.RawMessage {
+	user.settingsLock.RLock()
+	defer user.settingsLock.RUnlock()
+	return user.UserFriendList
+}
+
+func (user *User) setFriendList(friendList json.RawMessage) {
+	user.settingsLock.Lock()
+	defer user.settingsLock.Unlock()
+	user.UserFriendList = friendList
+}
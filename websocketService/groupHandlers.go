@@ -0,0 +1,551 @@
+package websocketService
+
+import (
+	"database/sql"
+	"dbUtils"
+	"errors"
+	fileserver "filesystem"
+	jsonprovider "jsonProvider"
+	"logger"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxGroupNameLength        = 64   // 群名称的最大字符数，不超过 groupdatatable.groupName 字段长度
+	maxGroupExplanationLength = 1000 // 群简介的最大字符数
+)
+
+var (
+	errNotGroupMember         = errors.New("不是群成员")
+	errAlreadyGroupMember     = errors.New("已是群成员")
+	errNotGroupMaster         = errors.New("只有群主可以执行此操作")
+	errNoGroupPermission      = errors.New("没有权限执行此操作")
+	errGroupMasterCannotLeave = errors.New("群主需先转让或解散群聊")
+	errInvalidGroupRole       = errors.New("无效的群角色")
+	errInvalidGroupSettings   = errors.New("无效的群设置")
+	errGroupInviteOnly        = errors.New("该群聊只能通过邀请加入")
+	errUserNotExist           = errors.New("用户不存在")
+	errGroupInviteRefused     = errors.New("对方拒绝接受你的邀请")
+	errGroupInvitePending     = errors.New("已邀请过对方，请等待对方处理")
+	errGroupInviteNotExist    = errors.New("群邀请不存在")
+	errGroupInviteForbidden   = errors.New("无权处理该群邀请")
+	errGroupInviteInvalid     = errors.New("邀请人已没有邀请权限，邀请已失效")
+)
+
+// groupErrorMessage 将群操作的错误转换为返回给客户端的提示
+func groupErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "群聊不存在"
+	case errors.Is(err, errNotGroupMember), errors.Is(err, errAlreadyGroupMember), errors.Is(err, errNotGroupMaster),
+		errors.Is(err, errNoGroupPermission), errors.Is(err, errGroupMasterCannotLeave), errors.Is(err, errInvalidGroupRole),
+		errors.Is(err, errInvalidGroupSettings), errors.Is(err, errGroupInviteOnly), errors.Is(err, errUserNotExist),
+		errors.Is(err, errGroupInviteRefused), errors.Is(err, errGroupInvitePending), errors.Is(err, errGroupInviteNotExist), errors.Is(err, errGroupInviteForbidden),
+		errors.Is(err, errGroupInviteInvalid), errors.Is(err, dbUtils.ErrGroupInviteHandled):
+		return err.Error()
+	default:
+		logger.Error("群操作失败:", err)
+		return "操作失败"
+	}
+}
+
+// handleInviteGroupMember 邀请用户入群，被邀请人通过 acceptGroupInvite 接受后才会加入群聊
+func handleInviteGroupMember(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GroupMemberRequest)
+	operatorID := session.User.UserId
+
+	invite, err := createGroupInvite(int(req.GroupID), operatorID, req.UserID)
+	res := jsonprovider.GroupMemberResponse{
+		GroupID: req.GroupID,
+		UserID:  req.UserID,
+		Success: err == nil,
+	}
+	if err != nil {
+		res.Message = groupErrorMessage(err)
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.InviteGroupMember, res))
+	if sendErr != nil {
+		logger.Error("群邀请结果回发失败:", sendErr)
+	}
+	if err == nil {
+		pushGroupInviteEvent(invite)
+	}
+}
+
+func createGroupInvite(groupID int, inviterID int, inviteeID int) (*jsonprovider.GroupInvite, error) {
+	if _, err := dbUtils.GetUserFromDB(inviteeID); err != nil {
+		return nil, errUserNotExist
+	}
+	if isBlockedBy(inviterID, inviteeID) {
+		return nil, errGroupInviteRefused
+	}
+	membership, err := dbUtils.GetGroupMembership(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasGroupPermission(membership, inviterID, groupPermissionInvite) {
+		return nil, errNoGroupPermission
+	}
+	if membership.IsMember(inviteeID) {
+		return nil, errAlreadyGroupMember
+	}
+	_, err = dbUtils.FindPendingGroupInvite(groupID, inviteeID)
+	if err == nil {
+		return nil, errGroupInvitePending
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	inviteID, err := dbUtils.CreateGroupInvite(groupID, inviterID, inviteeID)
+	if err != nil {
+		return nil, err
+	}
+	return dbUtils.GetGroupInvite(inviteID)
+}
+
+// handleAcceptGroupInvite 被邀请人接受邀请加入群聊，邀请人已失去邀请权限时邀请失效
+func handleAcceptGroupInvite(session *Session, request interface{}) {
+	userID := session.User.UserId
+	var members []int
+	invite, err := handleGroupInviteAction(session, configData.Commands.AcceptGroupInvite, request.(*jsonprovider.GroupInviteActionRequest),
+		func(invite *jsonprovider.GroupInvite) error {
+			return dbUtils.AcceptGroupInvite(invite, func(membership *dbUtils.GroupMembership) error {
+				if membership.IsMember(userID) {
+					return errAlreadyGroupMember
+				}
+				if !hasGroupPermission(membership, invite.InviterID, groupPermissionInvite) {
+					return errGroupInviteInvalid
+				}
+				membership.Members = append(membership.Members, userID)
+				members = append([]int(nil), membership.Members...)
+				return nil
+			})
+		})
+	if err != nil {
+		return
+	}
+	pushGroupEvent(members, jsonprovider.GroupMemberEvent{
+		GroupID:    invite.GroupID,
+		Event:      jsonprovider.GroupEventInvite,
+		OperatorID: invite.InviterID,
+		UserID:     userID,
+		TimeStamp:  int(time.Now().UnixNano()),
+	})
+}
+
+func handleDeclineGroupInvite(session *Session, request interface{}) {
+	handleGroupInviteAction(session, configData.Commands.DeclineGroupInvite, request.(*jsonprovider.GroupInviteActionRequest),
+		func(invite *jsonprovider.GroupInvite) error {
+			return dbUtils.DeclineGroupInvite(invite.InviteID)
+		})
+}
+
+// handleGroupInviteAction 只有被邀请人可以处理邀请，apply 成功后回发结果并向邀请人和被邀请人推送邀请状态
+func handleGroupInviteAction(session *Session, command string, req *jsonprovider.GroupInviteActionRequest, apply func(invite *jsonprovider.GroupInvite) error) (*jsonprovider.GroupInvite, error) {
+	invite, err := dbUtils.GetGroupInvite(req.InviteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errGroupInviteNotExist
+	}
+	if err == nil {
+		if invite.InviteeID != session.User.UserId {
+			err = errGroupInviteForbidden
+		} else if invite.State != jsonprovider.GroupInvitePending {
+			err = dbUtils.ErrGroupInviteHandled
+		} else {
+			err = apply(invite)
+		}
+	}
+
+	res := jsonprovider.GroupInviteActionResponse{
+		InviteID: req.InviteID,
+		Success:  err == nil,
+	}
+	if err != nil {
+		res.Message = groupErrorMessage(err)
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(command, res))
+	if sendErr != nil {
+		logger.Error("群邀请处理结果回发失败:", sendErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	invite, err = dbUtils.GetGroupInvite(invite.InviteID)
+	if err != nil {
+		logger.Error("获取群邀请失败:", err)
+		return nil, err
+	}
+	pushGroupInviteEvent(invite)
+	return invite, nil
+}
+
+// handleGetGroupInvites 获取收到的待处理入群邀请，离线期间收到的邀请通过此命令获取
+func handleGetGroupInvites(session *Session, _ interface{}) {
+	invites, err := dbUtils.GetPendingGroupInvites(session.User.UserId)
+	if err != nil {
+		logger.Error("获取群邀请失败:", err)
+		sendErrorResponse(session, configData.Commands.GetGroupInvites, "获取群邀请失败")
+		return
+	}
+	res := jsonprovider.GetGroupInvitesResponse{
+		Invites: invites,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetGroupInvites, res))
+	if err != nil {
+		logger.Error("群邀请回发失败:", err)
+	}
+}
+
+// pushGroupInviteEvent 向邀请人和被邀请人的在线会话推送入群邀请
+func pushGroupInviteEvent(invite *jsonprovider.GroupInvite) {
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.GroupInviteEvent, invite)
+	for _, userID := range []int{invite.InviterID, invite.InviteeID} {
+		_, err := sendMessageToUser(userID, message)
+		if err != nil {
+			logger.Debug("群邀请推送失败", err)
+		}
+	}
+}
+
+func handleJoinGroup(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GroupRequest)
+	userID := session.User.UserId
+
+	settings, err := dbUtils.GetGroupSettings(int(req.GroupID))
+	if err == nil && settings.JoinPolicy == jsonprovider.GroupJoinInvite {
+		err = errGroupInviteOnly
+	}
+	if err != nil {
+		finishGroupMemberChange(session, configData.Commands.JoinGroup, req.GroupID, userID, jsonprovider.GroupMemberEvent{}, err)
+		return
+	}
+	err = dbUtils.UpdateGroupMembership(int(req.GroupID), func(membership *dbUtils.GroupMembership) error {
+		if membership.IsMember(userID) {
+			return errAlreadyGroupMember
+		}
+		membership.Members = append(membership.Members, userID)
+		return nil
+	})
+	finishGroupMemberChange(session, configData.Commands.JoinGroup, req.GroupID, userID,
+		jsonprovider.GroupMemberEvent{Event: jsonprovider.GroupEventJoin}, err)
+}
+
+func handleLeaveGroup(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GroupRequest)
+	userID := session.User.UserId
+
+	err := dbUtils.UpdateGroupMembership(int(req.GroupID), func(membership *dbUtils.GroupMembership) error {
+		if !membership.IsMember(userID) {
+			return errNotGroupMember
+		}
+		if membership.Master == userID {
+			return errGroupMasterCannotLeave
+		}
+		membership.RemoveMember(userID)
+		return nil
+	})
+	finishGroupMemberChange(session, configData.Commands.LeaveGroup, req.GroupID, userID,
+		jsonprovider.GroupMemberEvent{Event: jsonprovider.GroupEventLeave}, err)
+}
+
+func handleKickGroupMember(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GroupMemberRequest)
+	operatorID := session.User.UserId
+
+	err := dbUtils.UpdateGroupMembership(int(req.GroupID), func(membership *dbUtils.GroupMembership) error {
+		if !membership.IsMember(req.UserID) {
+			return errNotGroupMember
+		}
+		if !canManageGroupMember(membership, operatorID, req.UserID, groupPermissionKick) {
+			return errNoGroupPermission
+		}
+		membership.RemoveMember(req.UserID)
+		return nil
+	})
+	finishGroupMemberChange(session, configData.Commands.KickGroupMember, req.GroupID, req.UserID,
+		jsonprovider.GroupMemberEvent{Event: jsonprovider.GroupEventKick}, err)
+}
+
+func handleTransferGroup(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GroupMemberRequest)
+	operatorID := session.User.UserId
+
+	err := dbUtils.UpdateGroupMembership(int(req.GroupID), func(membership *dbUtils.GroupMembership) error {
+		if membership.Master != operatorID {
+			return errNotGroupMaster
+		}
+		if req.UserID == operatorID || !membership.IsMember(req.UserID) {
+			return errNotGroupMember
+		}
+		// 原群主转为管理员
+		membership.Master = req.UserID
+		delete(membership.Roles, req.UserID)
+		membership.Roles[operatorID] = jsonprovider.GroupRoleAdmin
+		return nil
+	})
+	finishGroupMemberChange(session, configData.Commands.TransferGroup, req.GroupID, req.UserID,
+		jsonprovider.GroupMemberEvent{Event: jsonprovider.GroupEventTransfer}, err)
+}
+
+// handleSetGroupMemberRole 群主将成员设置为管理员、协管员或普通成员，转让群主使用 transferGroup
+func handleSetGroupMemberRole(session *Session, request interface{}) {
+	req := request.(*jsonprovider.SetGroupMemberRoleRequest)
+	operatorID := session.User.UserId
+
+	err := dbUtils.UpdateGroupMembership(int(req.GroupID), func(membership *dbUtils.GroupMembership) error {
+		if req.Role == jsonprovider.GroupRoleMaster {
+			return errInvalidGroupRole
+		}
+		if _, ok := groupRoleRank[req.Role]; !ok {
+			return errInvalidGroupRole
+		}
+		if !membership.IsMember(req.UserID) {
+			return errNotGroupMember
+		}
+		if !canManageGroupMember(membership, operatorID, req.UserID, groupPermissionSetRole) {
+			return errNoGroupPermission
+		}
+		if req.Role == jsonprovider.GroupRoleMember {
+			delete(membership.Roles, req.UserID)
+		} else {
+			membership.Roles[req.UserID] = req.Role
+		}
+		return nil
+	})
+	finishGroupMemberChange(session, configData.Commands.SetGroupMemberRole, req.GroupID, req.UserID,
+		jsonprovider.GroupMemberEvent{Event: jsonprovider.GroupEventRole, Role: req.Role}, err)
+}
+
+// handleMuteGroupMember 禁言或解除禁言群成员
+func handleMuteGroupMember(session *Session, request interface{}) {
+	req := request.(*jsonprovider.MuteGroupMemberRequest)
+	operatorID := session.User.UserId
+
+	event := jsonprovider.GroupMemberEvent{Event: jsonprovider.GroupEventUnmute}
+	if req.MuteSeconds > 0 {
+		event.Event = jsonprovider.GroupEventMute
+		event.MuteUntil = time.Now().Unix() + req.MuteSeconds
+	}
+	err := dbUtils.UpdateGroupMembership(int(req.GroupID), func(membership *dbUtils.GroupMembership) error {
+		if !membership.IsMember(req.UserID) {
+			return errNotGroupMember
+		}
+		if !canManageGroupMember(membership, operatorID, req.UserID, groupPermissionMute) {
+			return errNoGroupPermission
+		}
+		if event.MuteUntil > 0 {
+			membership.Mutes[req.UserID] = event.MuteUntil
+		} else {
+			delete(membership.Mutes, req.UserID)
+		}
+		return nil
+	})
+	finishGroupMemberChange(session, configData.Commands.MuteGroupMember, req.GroupID, req.UserID, event, err)
+}
+
+// handleChangeGroupSettings 修改群设置，成功后向所有在线群成员推送新的设置
+func handleChangeGroupSettings(session *Session, request interface{}) {
+	req := request.(*jsonprovider.ChangeGroupSettingsRequest)
+	operatorID := session.User.UserId
+
+	res := jsonprovider.ChangeGroupSettingsResponse{GroupID: req.GroupID}
+	membership, err := dbUtils.GetGroupMembership(int(req.GroupID))
+	if err == nil && !hasGroupPermission(membership, operatorID, groupPermissionChangeSettings) {
+		err = errNoGroupPermission
+	}
+	if err == nil && req.Permissions != nil && membership.Master != operatorID {
+		err = errNotGroupMaster
+	}
+	if err == nil {
+		res.Settings, err = dbUtils.UpdateGroupSettings(int(req.GroupID), func(settings *jsonprovider.GroupSettings) error {
+			return applyGroupSettings(settings, req)
+		})
+	}
+	res.Success = err == nil
+	if err != nil {
+		res.Settings = nil
+		res.Message = groupErrorMessage(err)
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.ChangeGroupSettings, res))
+	if sendErr != nil {
+		logger.Error("群设置修改结果回发失败:", sendErr)
+	}
+	if err != nil {
+		return
+	}
+
+	pushGroupEvent(membership.Members, jsonprovider.GroupSettingsEvent{
+		GroupID:    req.GroupID,
+		Event:      jsonprovider.GroupEventSettings,
+		OperatorID: operatorID,
+		Settings:   res.Settings,
+		TimeStamp:  int(time.Now().UnixNano()),
+	})
+}
+
+// applyGroupSettings 校验并应用请求中出现的设置项
+func applyGroupSettings(settings *jsonprovider.GroupSettings, req *jsonprovider.ChangeGroupSettingsRequest) error {
+	if req.Name != nil {
+		if !validGroupText(*req.Name, true, maxGroupNameLength) {
+			return errInvalidGroupSettings
+		}
+		settings.Name = *req.Name
+	}
+	if req.Avatar != nil {
+		err := checkGroupAvatar(*req.Avatar)
+		if err != nil {
+			return err
+		}
+		settings.Avatar = *req.Avatar
+	}
+	if req.Explanation != nil {
+		if !validGroupText(*req.Explanation, false, maxGroupExplanationLength) {
+			return errInvalidGroupSettings
+		}
+		settings.Explanation = *req.Explanation
+	}
+	if req.JoinPolicy != nil {
+		if *req.JoinPolicy != jsonprovider.GroupJoinOpen && *req.JoinPolicy != jsonprovider.GroupJoinInvite {
+			return errInvalidGroupSettings
+		}
+		settings.JoinPolicy = *req.JoinPolicy
+	}
+	if req.MuteAll != nil {
+		settings.MuteAll = *req.MuteAll
+	}
+	if req.HistoryVisibility != nil {
+		if *req.HistoryVisibility != jsonprovider.GroupHistoryAll && *req.HistoryVisibility != jsonprovider.GroupHistoryJoined {
+			return errInvalidGroupSettings
+		}
+		settings.HistoryVisibility = *req.HistoryVisibility
+	}
+	for role, names := range req.Permissions {
+		if names == nil {
+			delete(settings.Permissions, role)
+			continue
+		}
+		names, ok := validGroupPermissions(role, names)
+		if !ok {
+			return errInvalidGroupSettings
+		}
+		if settings.Permissions == nil {
+			settings.Permissions = make(map[string][]string)
+		}
+		settings.Permissions[role] = names
+	}
+	if len(settings.Permissions) == 0 {
+		settings.Permissions = nil
+	}
+	return nil
+}
+
+// validGroupText 校验群名称或简介为合法的UTF-8且不超过最大字符数
+func validGroupText(text string, required bool, maxLength int) bool {
+	if required && text == "" {
+		return false
+	}
+	return utf8.ValidString(text) && utf8.RuneCountInString(text) <= maxLength
+}
+
+// checkGroupAvatar 群头像为空（清除头像）或已上传图片的哈希
+func checkGroupAvatar(hash string) error {
+	if hash == "" {
+		return nil
+	}
+	_, contentType, err := fileserver.StatUpload(hash)
+	if errors.Is(err, fileserver.ErrInvalidUploadHash) || errors.Is(err, os.ErrNotExist) {
+		return errInvalidGroupSettings
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return errInvalidGroupSettings
+	}
+	return nil
+}
+
+// handleGetGroupMessages 获取群聊记录，群设置只允许查看入群后的消息时不返回入群前的消息
+func handleGetGroupMessages(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GetGroupMessagesRequest)
+	userID := session.User.UserId
+
+	membership, err := dbUtils.GetGroupMembership(int(req.GroupID))
+	if err == nil && !membership.IsMember(userID) {
+		err = errNotGroupMember
+	}
+	var settings *jsonprovider.GroupSettings
+	if err == nil {
+		settings, err = dbUtils.GetGroupSettings(int(req.GroupID))
+	}
+	if err != nil {
+		sendErrorResponse(session, configData.Commands.GetGroupMessages, groupErrorMessage(err))
+		return
+	}
+
+	onlyReceived := settings.HistoryVisibility != jsonprovider.GroupHistoryAll
+	messages, err := dbUtils.GetGroupMessages(userID, int(req.GroupID), req.StartTime, req.EndTime, onlyReceived, historyMessageTypes)
+	if err != nil {
+		logger.Error("Failed to get messages:", err)
+		sendErrorResponse(session, configData.Commands.GetGroupMessages, "获取群聊记录失败")
+		return
+	}
+
+	res := jsonprovider.GetGroupMessagesResponse{
+		GroupID:  req.GroupID,
+		Messages: messages,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetGroupMessages, res))
+	if err != nil {
+		logger.Error("Failed to send message history:", err)
+	}
+}
+
+// finishGroupMemberChange 回发操作结果，成功时向所有在线群成员及被操作的用户推送成员变动事件
+func finishGroupMemberChange(session *Session, command string, groupID int64, targetID int, event jsonprovider.GroupMemberEvent, err error) {
+	res := jsonprovider.GroupMemberResponse{
+		GroupID: groupID,
+		UserID:  targetID,
+		Success: err == nil,
+	}
+	if err != nil {
+		res.Message = groupErrorMessage(err)
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(command, res))
+	if sendErr != nil {
+		logger.Error("群操作结果回发失败:", sendErr)
+	}
+	if err != nil {
+		return
+	}
+
+	members, err := dbUtils.GetGroupMembers(int(groupID))
+	if err != nil {
+		logger.Error("Failed to get group members:", err)
+		return
+	}
+	if !dbUtils.ContainsMember(members, targetID) {
+		// 退群或被踢出的用户也需要收到事件
+		members = append(members, targetID)
+	}
+	event.GroupID = groupID
+	event.OperatorID = session.User.UserId
+	event.UserID = targetID
+	event.TimeStamp = int(time.Now().UnixNano())
+	pushGroupEvent(members, event)
+}
+
+// pushGroupEvent 向在线的群成员推送群事件
+func pushGroupEvent(members []int, event interface{}) {
+	message := jsonprovider.SdandarlizeJSON_byte(configData.Commands.GroupEvent, event)
+	for _, memberID := range members {
+		_, err := sendMessageToUser(memberID, message)
+		if err != nil {
+			logger.Debug("群事件推送失败", err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+)
+
+// groupPermission 群内可执行的操作
+type groupPermission int
+
+const (
+	groupPermissionSend           groupPermission = iota // 发送群消息
+	groupPermissionInvite                                // 邀请成员入群
+	groupPermissionChangeSettings                        // 修改群设置
+	groupPermissionMute                                  // 禁言成员
+	groupPermissionKick                                  // 踢出成员
+	groupPermissionSetRole                               // 设置成员角色
+	groupPermissionRecall                                // 撤回其他成员的消息
+	groupPermissionMentionAll                            // @全体成员
+)
+
+// groupPermissionMatrix 各群角色拥有的权限
+var groupPermissionMatrix = map[string]map[groupPermission]bool{
+	jsonprovider.GroupRoleMember: {
+		groupPermissionSend:   true,
+		groupPermissionInvite: true,
+	},
+	jsonprovider.GroupRoleModerator: {
+		groupPermissionSend:   true,
+		groupPermissionInvite: true,
+		groupPermissionMute:   true,
+	},
+	jsonprovider.GroupRoleAdmin: {
+		groupPermissionSend:           true,
+		groupPermissionInvite:         true,
+		groupPermissionChangeSettings: true,
+		groupPermissionMute:           true,
+		groupPermissionKick:           true,
+		groupPermissionRecall:         true,
+		groupPermissionMentionAll:     true,
+	},
+	jsonprovider.GroupRoleMaster: {
+		groupPermissionSend:           true,
+		groupPermissionInvite:         true,
+		groupPermissionChangeSettings: true,
+		groupPermissionMute:           true,
+		groupPermissionKick:           true,
+		groupPermissionSetRole:        true,
+		groupPermissionRecall:         true,
+		groupPermissionMentionAll:     true,
+	},
+}
+
+// groupPermissionNames 可以在群设置中按角色覆盖的权限及其名称，设置成员角色只属于群主，不可覆盖
+var groupPermissionNames = map[groupPermission]string{
+	groupPermissionSend:           jsonprovider.GroupPermissionSend,
+	groupPermissionInvite:         jsonprovider.GroupPermissionInvite,
+	groupPermissionChangeSettings: jsonprovider.GroupPermissionChangeSettings,
+	groupPermissionMute:           jsonprovider.GroupPermissionMute,
+	groupPermissionKick:           jsonprovider.GroupPermissionKick,
+	groupPermissionRecall:         jsonprovider.GroupPermissionRecall,
+	groupPermissionMentionAll:     jsonprovider.GroupPermissionMentionAll,
+}
+
+// groupRoleRank 群角色等级，只能管理等级低于自己的成员
+var groupRoleRank = map[string]int{
+	jsonprovider.GroupRoleMember:    0,
+	jsonprovider.GroupRoleModerator: 1,
+	jsonprovider.GroupRoleAdmin:     2,
+	jsonprovider.GroupRoleMaster:    3,
+}
+
+// hasGroupPermission 判断用户是否为群成员且其角色拥有指定权限
+func hasGroupPermission(membership *dbUtils.GroupMembership, userID int, permission groupPermission) bool {
+	if !membership.IsMember(userID) {
+		return false
+	}
+	role := membership.Role(userID)
+	if names, ok := membership.Permissions[role]; ok && role != jsonprovider.GroupRoleMaster {
+		name, ok := groupPermissionNames[permission]
+		if !ok {
+			return groupPermissionMatrix[role][permission]
+		}
+		for _, granted := range names {
+			if granted == name {
+				return true
+			}
+		}
+		return false
+	}
+	return groupPermissionMatrix[role][permission]
+}
+
+// validGroupPermissions 校验按角色覆盖的权限，群主的权限不可覆盖，返回去重后的权限列表
+func validGroupPermissions(role string, names []string) ([]string, bool) {
+	if role == jsonprovider.GroupRoleMaster {
+		return nil, false
+	}
+	if _, ok := groupRoleRank[role]; !ok {
+		return nil, false
+	}
+	valid := make(map[string]bool, len(groupPermissionNames))
+	for _, name := range groupPermissionNames {
+		valid[name] = true
+	}
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if !valid[name] {
+			return nil, false
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	return result, true
+}
+
+// canManageGroupMember 判断操作者是否拥有权限且角色等级高于被操作的成员
+func canManageGroupMember(membership *dbUtils.GroupMembership, operatorID int, targetID int, permission groupPermission) bool {
+	if !hasGroupPermission(membership, operatorID, permission) {
+		return false
+	}
+	return groupRoleRank[membership.Role(operatorID)] > groupRoleRank[membership.Role(targetID)]
+}
@@ -0,0 +1,104 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+	"reflect"
+	"testing"
+)
+
+const (
+	testMaster    = 1
+	testAdmin     = 2
+	testModerator = 3
+	testMember    = 4
+	testOutsider  = 5
+)
+
+func newTestMembership(permissions map[string][]string) *dbUtils.GroupMembership {
+	return &dbUtils.GroupMembership{
+		Master:  testMaster,
+		Members: []int{testMaster, testAdmin, testModerator, testMember},
+		Roles: map[int]string{
+			testAdmin:     jsonprovider.GroupRoleAdmin,
+			testModerator: jsonprovider.GroupRoleModerator,
+		},
+		Mutes:       map[int]int64{},
+		Permissions: permissions,
+	}
+}
+
+func TestHasGroupPermissionDefaults(t *testing.T) {
+	membership := newTestMembership(nil)
+	tests := []struct {
+		userID     int
+		permission groupPermission
+		want       bool
+	}{
+		{testMember, groupPermissionSend, true},
+		{testMember, groupPermissionMute, false},
+		{testModerator, groupPermissionMute, true},
+		{testModerator, groupPermissionKick, false},
+		{testAdmin, groupPermissionKick, true},
+		{testAdmin, groupPermissionSetRole, false},
+		{testMaster, groupPermissionSetRole, true},
+		{testOutsider, groupPermissionSend, false},
+	}
+	for _, test := range tests {
+		if got := hasGroupPermission(membership, test.userID, test.permission); got != test.want {
+			t.Errorf("hasGroupPermission(%d, %d) = %v，期望 %v", test.userID, test.permission, got, test.want)
+		}
+	}
+}
+
+func TestHasGroupPermissionOverrides(t *testing.T) {
+	membership := newTestMembership(map[string][]string{
+		jsonprovider.GroupRoleMember:    {jsonprovider.GroupPermissionInvite},
+		jsonprovider.GroupRoleModerator: {jsonprovider.GroupPermissionSend, jsonprovider.GroupPermissionKick},
+		jsonprovider.GroupRoleMaster:    {},
+	})
+
+	if hasGroupPermission(membership, testMember, groupPermissionSend) {
+		t.Error("覆盖后普通成员仍可以发言")
+	}
+	if !hasGroupPermission(membership, testMember, groupPermissionInvite) {
+		t.Error("覆盖后普通成员不能邀请")
+	}
+	if !hasGroupPermission(membership, testModerator, groupPermissionKick) || hasGroupPermission(membership, testModerator, groupPermissionMute) {
+		t.Error("协管员的权限没有按覆盖的列表取代默认权限")
+	}
+	if !hasGroupPermission(membership, testAdmin, groupPermissionMute) {
+		t.Error("未覆盖的角色没有使用默认权限")
+	}
+	if !hasGroupPermission(membership, testMaster, groupPermissionSend) || !hasGroupPermission(membership, testMaster, groupPermissionSetRole) {
+		t.Error("群主的权限不应被覆盖")
+	}
+	if !canManageGroupMember(membership, testModerator, testMember, groupPermissionKick) {
+		t.Error("拥有踢人权限的协管员不能踢出普通成员")
+	}
+	if canManageGroupMember(membership, testModerator, testAdmin, groupPermissionKick) {
+		t.Error("协管员可以踢出角色更高的管理员")
+	}
+}
+
+func TestValidGroupPermissions(t *testing.T) {
+	tests := []struct {
+		role  string
+		names []string
+		want  []string
+		ok    bool
+	}{
+		{jsonprovider.GroupRoleMember, []string{"send", "invite", "send"}, []string{"send", "invite"}, true},
+		{jsonprovider.GroupRoleAdmin, []string{}, []string{}, true},
+		{jsonprovider.GroupRoleMember, []string{"setRole"}, nil, false},
+		{jsonprovider.GroupRoleMember, []string{"fly"}, nil, false},
+		{jsonprovider.GroupRoleMaster, []string{"send"}, nil, false},
+		{"owner", []string{"send"}, nil, false},
+	}
+	for _, test := range tests {
+		got, ok := validGroupPermissions(test.role, test.names)
+		if ok != test.ok || ok && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("validGroupPermissions(%q, %v) = %v, %v，期望 %v, %v", test.role, test.names, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+	"logger"
+)
+
+// resolveMentions 去除重复的和发送者自己，被@的用户都是群成员时返回true
+func resolveMentions(membership *dbUtils.GroupMembership, senderID int, mentions []int) ([]int, bool) {
+	var result []int
+	for _, userID := range mentions {
+		if !membership.IsMember(userID) {
+			return nil, false
+		}
+		if userID != senderID && !dbUtils.ContainsMember(result, userID) {
+			result = append(result, userID)
+		}
+	}
+	return result, true
+}
+
+// handleGetUnreadMentions 获取@自己且尚未标记为已读的群消息，离线期间收到的@消息同样保留
+func handleGetUnreadMentions(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GetUnreadMentionsRequest)
+
+	messages, err := dbUtils.GetUnreadMentions(session.User.UserId, int(req.GroupID))
+	if err != nil {
+		logger.Error("获取未读@消息失败:", err)
+		sendErrorResponse(session, configData.Commands.GetUnreadMentions, "获取未读@消息失败")
+		return
+	}
+	res := jsonprovider.GetUnreadMentionsResponse{
+		GroupID:  req.GroupID,
+		Messages: messages,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetUnreadMentions, res))
+	if err != nil {
+		logger.Error("未读@消息回发失败:", err)
+	}
+}
+
+// handleMarkMentionsRead 将群聊中到指定消息为止的@消息标记为已读，同步到用户的所有在线会话
+func handleMarkMentionsRead(session *Session, request interface{}) {
+	req := request.(*jsonprovider.MarkMentionsReadRequest)
+	userID := session.User.UserId
+
+	err := dbUtils.MarkMentionsRead(userID, int(req.GroupID), req.MessageID)
+	if err != nil {
+		logger.Error("标记@消息已读失败:", err)
+	}
+	res := jsonprovider.MarkMentionsReadResponse{
+		GroupID:   req.GroupID,
+		MessageID: req.MessageID,
+		Success:   err == nil,
+	}
+	payload := jsonprovider.SdandarlizeJSON_byte(configData.Commands.MarkMentionsRead, res)
+	if res.Success {
+		_, err = sendMessageToUser(userID, payload)
+	} else {
+		err = session.send(payload)
+	}
+	if err != nil {
+		logger.Error("标记@消息已读结果回发失败:", err)
+	}
+}
@@ -0,0 +1,306 @@
+package websocketService
+
+import (
+	"database/sql"
+	"dbUtils"
+	"encoding/json"
+	"errors"
+	jsonprovider "jsonProvider"
+	"logger"
+	"time"
+)
+
+var (
+	errNoMessagePermission  = errors.New("没有权限操作该消息")
+	errMessageRecalled      = errors.New("消息已撤回")
+	errMessageRecallTimeout = errors.New("已超过可撤回的时间")
+	errMessageEditTimeout   = errors.New("已超过可编辑的时间")
+	errEmptyMessageBody     = errors.New("消息内容不能为空")
+)
+
+// messageErrorMessage 将消息操作的错误转换为返回给客户端的提示
+func messageErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "消息不存在"
+	case errors.Is(err, errNoMessagePermission), errors.Is(err, errMessageRecalled), errors.Is(err, errMessageRecallTimeout),
+		errors.Is(err, errMessageEditTimeout), errors.Is(err, errEmptyMessageBody), errors.Is(err, errInvalidReaction):
+		return err.Error()
+	default:
+		logger.Error("消息操作失败:", err)
+		return "操作失败"
+	}
+}
+
+// handleRecallMessage 撤回消息，消息只标记为已撤回，撤回事件推送给在线的参与者，离线的参与者通过撤回通知消息获取
+func handleRecallMessage(session *Session, request interface{}) {
+	req := request.(*jsonprovider.RecallMessageRequest)
+	operatorID := session.User.UserId
+
+	message, err := dbUtils.GetMessage(req.MessageID)
+	if err == nil {
+		err = checkRecallPermission(message, operatorID, time.Now())
+	}
+	var participants []int
+	if err == nil {
+		participants, err = messageParticipants(message, operatorID)
+	}
+	var event jsonprovider.MessageRecallEvent
+	if err == nil {
+		event = jsonprovider.MessageRecallEvent{
+			MessageID:  message.MessageID,
+			SenderID:   message.SenderID,
+			ReceiverID: message.ReceiverID,
+			GroupID:    message.GroupID,
+			OperatorID: operatorID,
+			TimeStamp:  int(time.Now().UnixNano()),
+		}
+		var notice []byte
+		notice, err = json.Marshal(event)
+		if err == nil {
+			event.NoticeID, err = dbUtils.RecallMessage(message, operatorID, string(notice), RecallNoticeMessage, participants)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errMessageRecalled
+		}
+	}
+
+	res := jsonprovider.RecallMessageResponse{
+		MessageID: req.MessageID,
+		Success:   err == nil,
+	}
+	if err != nil {
+		res.Message = messageErrorMessage(err)
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.RecallMessage, res))
+	if sendErr != nil {
+		logger.Error("撤回结果回发失败:", sendErr)
+	}
+	if err != nil {
+		return
+	}
+
+	// 已撤回的消息不再重发
+	processingStateMessagesLock.Lock()
+	delete(processingStateMessages, message.MessageID)
+	processingStateMessagesLock.Unlock()
+
+	received := pushMessageEvent(append(participants, operatorID), configData.Commands.MessageRecallEvent, event)
+	markNoticeDelivered(event.NoticeID, received)
+}
+
+// checkRecallPermission 发送者可以在时限内撤回自己的消息，拥有撤回权限的群成员可以随时撤回等级低于自己的成员的群消息
+func checkRecallPermission(message *jsonprovider.Message, operatorID int, now time.Time) error {
+	if message.MessageType != UserMessage {
+		return errNoMessagePermission
+	}
+	if message.Recalled {
+		return errMessageRecalled
+	}
+	if message.SenderID != operatorID {
+		if message.GroupID == 0 {
+			return errNoMessagePermission
+		}
+		membership, err := dbUtils.GetGroupMembership(message.GroupID)
+		if err != nil {
+			return err
+		}
+		if !canManageGroupMember(membership, operatorID, message.SenderID, groupPermissionRecall) {
+			return errNoMessagePermission
+		}
+		return nil
+	}
+	window := time.Duration(configData.MessageRecallWindowSeconds) * time.Second
+	if now.Sub(time.Unix(0, int64(message.Time))) > window {
+		return errMessageRecallTimeout
+	}
+	return nil
+}
+
+// handleEditMessage 修改自己发送的消息，旧版本保存在编辑记录中，编辑事件推送给在线的参与者，离线的参与者通过编辑通知消息获取
+func handleEditMessage(session *Session, request interface{}) {
+	req := request.(*jsonprovider.EditMessageRequest)
+	userID := session.User.UserId
+
+	message, err := dbUtils.GetMessage(req.MessageID)
+	if err == nil {
+		err = checkEditPermission(message, userID, req.MessageBody, time.Now())
+	}
+	var participants []int
+	if err == nil {
+		participants, err = messageParticipants(message, userID)
+	}
+	var event jsonprovider.MessageEditEvent
+	if err == nil {
+		event = jsonprovider.MessageEditEvent{
+			MessageID:   message.MessageID,
+			SenderID:    message.SenderID,
+			ReceiverID:  message.ReceiverID,
+			GroupID:     message.GroupID,
+			MessageBody: req.MessageBody,
+			EditedAt:    int(time.Now().UnixNano()),
+		}
+		var notice []byte
+		notice, err = json.Marshal(event)
+		if err == nil {
+			event.NoticeID, err = dbUtils.EditMessage(message, req.MessageBody, string(notice), EditNoticeMessage, participants, int64(event.EditedAt))
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errMessageRecalled
+		}
+	}
+
+	res := jsonprovider.EditMessageResponse{
+		MessageID: req.MessageID,
+		Success:   err == nil,
+	}
+	if err != nil {
+		res.Message = messageErrorMessage(err)
+	} else {
+		res.EditedAt = event.EditedAt
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.EditMessage, res))
+	if sendErr != nil {
+		logger.Error("编辑结果回发失败:", sendErr)
+	}
+	if err != nil {
+		return
+	}
+
+	received := pushMessageEvent(append(participants, userID), configData.Commands.MessageEditEvent, event)
+	markNoticeDelivered(event.NoticeID, received)
+}
+
+// checkEditPermission 只有发送者可以在时限内编辑自己发送的、未撤回的消息，文本消息的内容不能为空
+// 其他种类的消息只能编辑 messageBody 中的说明文字，元数据不能修改
+func checkEditPermission(message *jsonprovider.Message, userID int, messageBody string, now time.Time) error {
+	if message.MessageType != UserMessage || message.SenderID != userID {
+		return errNoMessagePermission
+	}
+	if message.Recalled {
+		return errMessageRecalled
+	}
+	window := time.Duration(configData.MessageEditWindowSeconds) * time.Second
+	if now.Sub(time.Unix(0, int64(message.Time))) > window {
+		return errMessageEditTimeout
+	}
+	if messageBody == "" && message.Kind == jsonprovider.MessageKindText {
+		return errEmptyMessageBody
+	}
+	return nil
+}
+
+// handleGetMessageEdits 获取消息的历史版本，只有可以看到该消息的会话参与者可以查看
+func handleGetMessageEdits(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GetMessageEditsRequest)
+	userID := session.User.UserId
+
+	message, err := dbUtils.GetMessage(req.MessageID)
+	if err == nil {
+		var visible bool
+		visible, err = canViewMessage(message, userID)
+		if err == nil && !visible {
+			err = errNoMessagePermission
+		}
+	}
+	if err == nil && message.Recalled {
+		err = errMessageRecalled
+	}
+	var edits []jsonprovider.MessageEdit
+	if err == nil {
+		edits, err = dbUtils.GetMessageEdits(req.MessageID)
+	}
+	if err != nil {
+		sendErrorResponse(session, configData.Commands.GetMessageEdits, messageErrorMessage(err))
+		return
+	}
+
+	res := jsonprovider.GetMessageEditsResponse{
+		MessageID: req.MessageID,
+		Edits:     edits,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetMessageEdits, res))
+	if err != nil {
+		logger.Error("编辑记录回发失败:", err)
+	}
+}
+
+// isMessageParticipant 判断用户是否为消息所在会话的参与者
+func isMessageParticipant(message *jsonprovider.Message, userID int) (bool, error) {
+	if message.GroupID == 0 {
+		return message.SenderID == userID || message.ReceiverID == userID, nil
+	}
+	membership, err := dbUtils.GetGroupMembership(message.GroupID)
+	if err != nil {
+		return false, err
+	}
+	return membership.IsMember(userID), nil
+}
+
+// canViewMessage 判断用户是否为消息所在会话的参与者且可以看到该消息
+func canViewMessage(message *jsonprovider.Message, userID int) (bool, error) {
+	participant, err := isMessageParticipant(message, userID)
+	if err != nil || !participant || message.GroupID == 0 {
+		return participant, err
+	}
+	return canViewGroupHistory(message, userID)
+}
+
+// canViewGroupHistory 群设置只允许查看入群后的消息时，只有发送者和收到该消息的成员可以看到，调用前需确认用户为群成员
+func canViewGroupHistory(message *jsonprovider.Message, userID int) (bool, error) {
+	if message.SenderID == userID {
+		return true, nil
+	}
+	settings, err := dbUtils.GetGroupSettings(message.GroupID)
+	if err != nil {
+		return false, err
+	}
+	if settings.HistoryVisibility == jsonprovider.GroupHistoryAll {
+		return true, nil
+	}
+	return dbUtils.IsMessageRecipient(message.MessageID, userID)
+}
+
+// messageParticipants 获取除操作者外需要收到消息事件的用户，即消息的所有接收者及发送者
+func messageParticipants(message *jsonprovider.Message, operatorID int) ([]int, error) {
+	recipients, err := dbUtils.GetMessageRecipients(message.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	return removeUser(append(recipients, message.SenderID), operatorID), nil
+}
+
+// pushMessageEvent 向在线的用户推送消息事件，返回推送成功的用户
+func pushMessageEvent(users []int, command string, event interface{}) []int {
+	payload := jsonprovider.SdandarlizeJSON_byte(command, event)
+	received := make([]int, 0, len(users))
+	for _, userID := range users {
+		sent, err := sendMessageToUser(userID, payload)
+		if err != nil {
+			logger.Debug("消息事件推送失败", err)
+		}
+		if sent {
+			received = append(received, userID)
+		}
+	}
+	return received
+}
+
+// markNoticeDelivered 已收到事件推送的用户不再通过离线消息获取对应的通知消息
+func markNoticeDelivered(noticeID int, users []int) {
+	err := dbUtils.MarkMessageDeliveredTo(noticeID, users)
+	if err != nil {
+		logger.Error("更新通知消息投递状态失败:", err)
+	}
+}
+
+// removeUser 返回去掉userID后的用户列表
+func removeUser(users []int, userID int) []int {
+	result := make([]int, 0, len(users))
+	for _, user := range users {
+		if user != userID {
+			result = append(result, user)
+		}
+	}
+	return result
+}
@@ -0,0 +1,189 @@
+package websocketService
+
+import (
+	"bytes"
+	"config"
+	"dbUtils"
+	"encoding/json"
+	"errors"
+	fileserver "filesystem"
+	jsonprovider "jsonProvider"
+	"logger"
+	"mime"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxContentTextLength 文件名、地点名称与地址的最大长度
+const maxContentTextLength = 255
+
+var errInvalidMessageContent = errors.New("消息内容与消息种类不符")
+
+// resolveMessageContent 按消息种类校验消息内容与元数据，返回实际使用的种类、规范化后的元数据和消息类型
+// kind 为空时为 text；系统通知只有管理员可以发送，保存为 SystemMessage
+func resolveMessageContent(user *User, kind string, messageBody string, content json.RawMessage) (string, json.RawMessage, int, error) {
+	if kind == "" {
+		kind = jsonprovider.MessageKindText
+	}
+	var value interface{}
+	var err error
+	switch kind {
+	case jsonprovider.MessageKindText:
+		if messageBody == "" || hasContent(content) {
+			return "", nil, 0, errInvalidMessageContent
+		}
+		return kind, nil, UserMessage, nil
+	case jsonprovider.MessageKindSystem:
+		if user.UserPermission < config.PermissionOperator {
+			return "", nil, 0, errNoMessagePermission
+		}
+		if messageBody == "" || hasContent(content) {
+			return "", nil, 0, errInvalidMessageContent
+		}
+		return kind, nil, SystemMessage, nil
+	case jsonprovider.MessageKindImage:
+		value, err = resolveImageContent(content)
+	case jsonprovider.MessageKindFile:
+		value, err = resolveFileContent(content)
+	case jsonprovider.MessageKindLocation:
+		value, err = resolveLocationContent(content)
+	case jsonprovider.MessageKindCard:
+		value, err = resolveCardContent(content)
+	default:
+		err = errInvalidMessageContent
+	}
+	if err != nil {
+		return "", nil, 0, err
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return kind, normalized, UserMessage, nil
+}
+
+// messageContentRefusal 将校验消息内容时的错误转换为拒绝发送的消息状态
+func messageContentRefusal(err error) int {
+	switch {
+	case errors.Is(err, errInvalidMessageContent):
+		return jsonprovider.MessageInvalid
+	case errors.Is(err, errNoMessagePermission):
+		return jsonprovider.UserRefused
+	default:
+		logger.Error("校验消息内容失败:", err)
+		return jsonprovider.ServerSendError
+	}
+}
+
+func hasContent(content json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(content)
+	return len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null"))
+}
+
+// decodeContent 严格解析元数据，缺少元数据或包含未知字段时返回 errInvalidMessageContent
+func decodeContent(content json.RawMessage, value interface{}) error {
+	if !hasContent(content) {
+		return errInvalidMessageContent
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if decoder.Decode(value) != nil {
+		return errInvalidMessageContent
+	}
+	return nil
+}
+
+func validContentText(text string, required bool) bool {
+	if required && text == "" {
+		return false
+	}
+	return len(text) <= maxContentTextLength && utf8.ValidString(text)
+}
+
+// statUpload 获取已上传文件的大小和类型，文件不存在时返回 errInvalidMessageContent
+func statUpload(hash string) (int64, string, error) {
+	size, contentType, err := fileserver.StatUpload(hash)
+	if errors.Is(err, fileserver.ErrInvalidUploadHash) || errors.Is(err, os.ErrNotExist) {
+		return 0, "", errInvalidMessageContent
+	}
+	return size, contentType, err
+}
+
+// resolveImageContent 图片必须已经上传，大小与类型由服务器根据文件填写
+func resolveImageContent(content json.RawMessage) (*jsonprovider.ImageContent, error) {
+	var image jsonprovider.ImageContent
+	err := decodeContent(content, &image)
+	if err != nil {
+		return nil, err
+	}
+	if image.Width < 0 || image.Height < 0 {
+		return nil, errInvalidMessageContent
+	}
+	size, contentType, err := statUpload(image.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, errInvalidMessageContent
+	}
+	image.Size = size
+	image.Mime = contentType
+	return &image, nil
+}
+
+// resolveFileContent 文件必须已经上传且大小一致，未填写类型时使用根据文件内容检测出的类型
+func resolveFileContent(content json.RawMessage) (*jsonprovider.FileContent, error) {
+	var file jsonprovider.FileContent
+	err := decodeContent(content, &file)
+	if err != nil {
+		return nil, err
+	}
+	if !validContentText(file.Name, true) || strings.ContainsAny(file.Name, "/\\") {
+		return nil, errInvalidMessageContent
+	}
+	size, contentType, err := statUpload(file.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if file.Size != size {
+		return nil, errInvalidMessageContent
+	}
+	if file.Mime == "" {
+		file.Mime = contentType
+	} else if _, _, err = mime.ParseMediaType(file.Mime); err != nil || len(file.Mime) > maxContentTextLength {
+		return nil, errInvalidMessageContent
+	}
+	return &file, nil
+}
+
+func resolveLocationContent(content json.RawMessage) (*jsonprovider.LocationContent, error) {
+	var location jsonprovider.LocationContent
+	err := decodeContent(content, &location)
+	if err != nil {
+		return nil, err
+	}
+	valid := location.Latitude >= -90 && location.Latitude <= 90 &&
+		location.Longitude >= -180 && location.Longitude <= 180 &&
+		validContentText(location.Name, false) && validContentText(location.Address, false)
+	if !valid {
+		return nil, errInvalidMessageContent
+	}
+	return &location, nil
+}
+
+// resolveCardContent 名片中的用户必须存在，用户名与头像使用发送时的用户数据
+func resolveCardContent(content json.RawMessage) (*jsonprovider.CardContent, error) {
+	var card jsonprovider.CardContent
+	err := decodeContent(content, &card)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := dbUtils.GetUserFromDB(card.UserID)
+	if err != nil {
+		return nil, errInvalidMessageContent
+	}
+	card.UserName = userData.UserName
+	card.UserAvatar = userData.UserAvatar
+	return &card, nil
+}
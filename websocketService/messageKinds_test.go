@@ -0,0 +1,125 @@
+package websocketService
+
+import (
+	"config"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	jsonprovider "jsonProvider"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testUpload 在临时目录的 uploads 中保存文件并切换工作目录，返回文件哈希
+func testUpload(t *testing.T, data []byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	err := os.MkdirAll(filepath.Join(dir, "uploads"), os.ModePerm)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "uploads", hash), data, 0o644)
+	}
+	if err != nil {
+		t.Fatal("写入测试文件失败:", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+	return hash
+}
+
+func TestResolveMessageContentText(t *testing.T) {
+	user := &User{User: jsonprovider.User{UserId: 1, UserPermission: config.PermissionOrdinaryUser}}
+	operator := &User{User: jsonprovider.User{UserId: 2, UserPermission: config.PermissionOperator}}
+	tests := []struct {
+		name        string
+		user        *User
+		kind        string
+		body        string
+		content     string
+		wantKind    string
+		wantType    int
+		wantErr     error
+		wantContent bool
+	}{
+		{"省略种类时为文本", user, "", "hello", "", jsonprovider.MessageKindText, UserMessage, nil, false},
+		{"content为null视为没有元数据", user, jsonprovider.MessageKindText, "hello", "null", jsonprovider.MessageKindText, UserMessage, nil, false},
+		{"文本不能为空", user, jsonprovider.MessageKindText, "", "", "", 0, errInvalidMessageContent, false},
+		{"文本不能带元数据", user, jsonprovider.MessageKindText, "hello", `{"a":1}`, "", 0, errInvalidMessageContent, false},
+		{"普通用户不能发送系统通知", user, jsonprovider.MessageKindSystem, "notice", "", "", 0, errNoMessagePermission, false},
+		{"管理员发送系统通知", operator, jsonprovider.MessageKindSystem, "notice", "", jsonprovider.MessageKindSystem, SystemMessage, nil, false},
+		{"未知的种类", user, "sticker", "hello", "", "", 0, errInvalidMessageContent, false},
+		{"位置", user, jsonprovider.MessageKindLocation, "", `{"latitude":31.2,"longitude":121.5,"name":"上海"}`, jsonprovider.MessageKindLocation, UserMessage, nil, true},
+		{"位置超出范围", user, jsonprovider.MessageKindLocation, "", `{"latitude":91,"longitude":0}`, "", 0, errInvalidMessageContent, false},
+		{"位置包含未知字段", user, jsonprovider.MessageKindLocation, "", `{"latitude":0,"longitude":0,"floor":3}`, "", 0, errInvalidMessageContent, false},
+		{"位置缺少元数据", user, jsonprovider.MessageKindLocation, "", "", "", 0, errInvalidMessageContent, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kind, content, messageType, err := resolveMessageContent(test.user, test.kind, test.body, json.RawMessage(test.content))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("错误为 %v，期望 %v", err, test.wantErr)
+			}
+			if kind != test.wantKind || messageType != test.wantType || (content != nil) != test.wantContent {
+				t.Errorf("结果为 %q, %s, %d", kind, content, messageType)
+			}
+		})
+	}
+}
+
+func TestResolveMessageContentUploads(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	hash := testUpload(t, png)
+	user := &User{User: jsonprovider.User{UserId: 1, UserPermission: config.PermissionOrdinaryUser}}
+
+	_, content, _, err := resolveMessageContent(user, jsonprovider.MessageKindImage, "", json.RawMessage(`{"hash":"`+hash+`","width":1,"height":1,"size":1,"mime":"text/plain"}`))
+	if err != nil {
+		t.Fatal("图片消息校验失败:", err)
+	}
+	var image jsonprovider.ImageContent
+	if err = json.Unmarshal(content, &image); err != nil {
+		t.Fatal(err)
+	}
+	if image.Size != int64(len(png)) || image.Mime != "image/png" {
+		t.Errorf("图片的大小与类型应由服务器填写，得到 %d %q", image.Size, image.Mime)
+	}
+
+	_, content, _, err = resolveMessageContent(user, jsonprovider.MessageKindFile, "", json.RawMessage(`{"hash":"`+hash+`","name":"a.png","size":40}`))
+	if err != nil {
+		t.Fatal("文件消息校验失败:", err)
+	}
+	var file jsonprovider.FileContent
+	if err = json.Unmarshal(content, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Mime != "image/png" {
+		t.Errorf("未填写类型时应使用检测出的类型，得到 %q", file.Mime)
+	}
+
+	invalid := []struct {
+		name    string
+		kind    string
+		content string
+	}{
+		{"文件大小不一致", jsonprovider.MessageKindFile, `{"hash":"` + hash + `","name":"a.png","size":1}`},
+		{"文件名包含路径", jsonprovider.MessageKindFile, `{"hash":"` + hash + `","name":"../a.png","size":40}`},
+		{"文件未上传", jsonprovider.MessageKindImage, `{"hash":"` + hex.EncodeToString(make([]byte, sha256.Size)) + `"}`},
+		{"哈希格式错误", jsonprovider.MessageKindImage, `{"hash":"../../etc/passwd"}`},
+	}
+	for _, test := range invalid {
+		_, _, _, err = resolveMessageContent(user, test.kind, "", json.RawMessage(test.content))
+		if !errors.Is(err, errInvalidMessageContent) {
+			t.Errorf("%s: 错误为 %v，期望 errInvalidMessageContent", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+	"logger"
+)
+
+// handleSync 按游标分页下发消息，客户端携带的cursor视为已收到，重复请求同一cursor会得到相同的结果
+func handleSync(session *Session, request interface{}) {
+	req := request.(*jsonprovider.SyncRequest)
+	userID := session.User.UserId
+
+	var cursor int
+	if req.Cursor != nil {
+		cursor = *req.Cursor
+		// 客户端已收到cursor及之前的消息，保存游标并更新投递状态
+		err := dbUtils.SaveSyncPointer(userID, session.DeviceID, cursor)
+		if err != nil {
+			logger.Error("保存同步游标失败:", err)
+		}
+		err = dbUtils.MarkMessagesDeliveredUpTo(userID, cursor)
+		if err != nil {
+			logger.Error("更新消息投递状态失败:", err)
+		}
+	} else {
+		var err error
+		cursor, err = dbUtils.GetSyncPointer(userID, session.DeviceID)
+		if err != nil {
+			logger.Error("读取同步游标失败:", err)
+			sendErrorResponse(session, configData.Commands.Sync, "读取同步游标失败")
+			return
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > configData.SyncMaxPageSize {
+		limit = configData.SyncMaxPageSize
+	}
+
+	// 多取一条用于判断是否还有下一页
+	messages, err := dbUtils.GetMessagesAfter(userID, cursor, limit+1)
+	if err != nil {
+		logger.Error("同步消息失败:", err)
+		sendErrorResponse(session, configData.Commands.Sync, "同步消息失败")
+		return
+	}
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	nextCursor := cursor
+	if len(messages) > 0 {
+		nextCursor = messages[len(messages)-1].MessageID
+	}
+
+	res := jsonprovider.SyncResponse{
+		Messages: messages,
+		Cursor:   nextCursor,
+		HasMore:  hasMore,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.Sync, res))
+	if err != nil {
+		logger.Error("同步消息回发失败:", err)
+	}
+}
@@ -0,0 +1,117 @@
+package websocketService
+
+import (
+	"database/sql"
+	"dbUtils"
+	"errors"
+	jsonprovider "jsonProvider"
+	"logger"
+)
+
+var errInvalidMessageReference = errors.New("引用的消息不存在或不可见")
+
+// resolveMessageReference 校验发送消息时引用的消息和所属话题，返回实际使用的 replyTo 与 threadRoot
+// 被引用的消息必须属于同一会话、未撤回且发送者可以看到；threadRoot 指向话题中的回复时改为该话题的根消息
+func resolveMessageReference(userID int, receiverID int, groupID int, onlyReceived bool, replyTo int, threadRoot int) (int, int, error) {
+	if replyTo != 0 {
+		_, err := getReferencedMessage(userID, receiverID, groupID, onlyReceived, replyTo)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if threadRoot != 0 {
+		root, err := getReferencedMessage(userID, receiverID, groupID, onlyReceived, threadRoot)
+		if err != nil {
+			return 0, 0, err
+		}
+		if root.ThreadRoot != 0 {
+			threadRoot = root.ThreadRoot
+		}
+	}
+	return replyTo, threadRoot, nil
+}
+
+func getReferencedMessage(userID int, receiverID int, groupID int, onlyReceived bool, messageID int) (*jsonprovider.Message, error) {
+	message, err := dbUtils.GetMessage(messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidMessageReference
+	}
+	if err != nil {
+		return nil, err
+	}
+	if message.MessageType != UserMessage || message.Recalled || message.GroupID != groupID {
+		return nil, errInvalidMessageReference
+	}
+	if groupID == 0 {
+		inConversation := (message.SenderID == userID && message.ReceiverID == receiverID) ||
+			(message.SenderID == receiverID && message.ReceiverID == userID)
+		if !inConversation {
+			return nil, errInvalidMessageReference
+		}
+		return message, nil
+	}
+	// 群设置只允许查看入群后的消息时，不能引用入群前的消息
+	if onlyReceived && message.SenderID != userID {
+		received, err := dbUtils.IsMessageRecipient(messageID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !received {
+			return nil, errInvalidMessageReference
+		}
+	}
+	return message, nil
+}
+
+// messageReferenceRefusal 将校验引用时的错误转换为拒绝发送的消息状态
+func messageReferenceRefusal(err error) int {
+	if errors.Is(err, errInvalidMessageReference) {
+		return jsonprovider.MessageInvalid
+	}
+	logger.Error("校验引用的消息失败:", err)
+	return jsonprovider.ServerSendError
+}
+
+// handleGetThreadMessages 获取话题的根消息及其所有回复，群设置只允许查看入群后的消息时不返回入群前的消息
+func handleGetThreadMessages(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GetThreadMessagesRequest)
+	userID := session.User.UserId
+
+	root, err := dbUtils.GetMessage(req.ThreadRoot)
+	onlyReceived := false
+	if err == nil {
+		var visible bool
+		visible, err = isMessageParticipant(root, userID)
+		if err == nil && !visible {
+			err = errNoMessagePermission
+		}
+	}
+	if err == nil && root.GroupID != 0 {
+		var settings *jsonprovider.GroupSettings
+		settings, err = dbUtils.GetGroupSettings(root.GroupID)
+		if err == nil {
+			onlyReceived = settings.HistoryVisibility != jsonprovider.GroupHistoryAll
+		}
+	}
+	var messages []jsonprovider.Message
+	if err == nil {
+		threadRoot := req.ThreadRoot
+		if root.ThreadRoot != 0 {
+			threadRoot = root.ThreadRoot
+		}
+		messages, err = dbUtils.GetThreadMessages(userID, threadRoot, onlyReceived)
+	}
+	if err != nil {
+		sendErrorResponse(session, configData.Commands.GetThreadMessages, messageErrorMessage(err))
+		return
+	}
+
+	res := jsonprovider.GetThreadMessagesResponse{
+		ThreadRoot: req.ThreadRoot,
+		Messages:   messages,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetThreadMessages, res))
+	if err != nil {
+		logger.Error("话题消息回发失败:", err)
+	}
+}
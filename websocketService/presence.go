@@ -0,0 +1,221 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+	"logger"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPresenceQuery getPresence 一次最多查询的用户数
+const maxPresenceQuery = 200
+
+// presenceLock 按用户串行化在线状态推送，避免用户断开后立即重连时好友先收到新的上线、后收到旧的离线
+type presenceLock struct {
+	sync.Mutex
+	refs int // 持有或等待该锁的协程数，为0时从 presenceLocks 中删除
+}
+
+var (
+	presenceLocks     = make(map[int]*presenceLock)
+	presenceLocksLock sync.Mutex
+)
+
+// lockPresence 获取用户的在线状态锁，返回的函数释放锁，没有协程使用时删除该锁
+func lockPresence(userID int) func() {
+	presenceLocksLock.Lock()
+	lock, ok := presenceLocks[userID]
+	if !ok {
+		lock = new(presenceLock)
+		presenceLocks[userID] = lock
+	}
+	lock.refs++
+	presenceLocksLock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		presenceLocksLock.Lock()
+		defer presenceLocksLock.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(presenceLocks, userID)
+		}
+	}
+}
+
+// Presence 返回用户设置的在线状态
+func (user *User) Presence() string {
+	user.settingsLock.RLock()
+	defer user.settingsLock.RUnlock()
+	if user.presence == "" {
+		return jsonprovider.PresenceOnline
+	}
+	return user.presence
+}
+
+func (user *User) setPresence(status string) {
+	user.settingsLock.Lock()
+	defer user.settingsLock.Unlock()
+	user.presence = status
+}
+
+// userConnected 用户的第一个会话登录后向好友推送上线状态，推送前用户已经断开时不推送
+func userConnected(user *User) {
+	unlock := lockPresence(user.UserId)
+	defer unlock()
+	if getOnlineUser(user.UserId) != user {
+		return
+	}
+	pushUserState(user, user.Presence(), 0)
+}
+
+// userDisconnected 用户的最后一个会话断开后保存最后在线时间并向好友推送离线状态，推送前用户已经重新连接时不推送
+func userDisconnected(user *User) {
+	lastSeen := time.Now().UnixNano()
+	err := dbUtils.SaveLastSeen(user.UserId, lastSeen)
+	if err != nil {
+		logger.Error("保存最后在线时间失败:", err)
+	}
+	unlock := lockPresence(user.UserId)
+	defer unlock()
+	if getOnlineUser(user.UserId) != nil {
+		return
+	}
+	pushUserState(user, jsonprovider.PresenceOffline, lastSeen)
+}
+
+// pushUserState 向在线且可以看到用户在线状态的好友推送 userStateEvent，被用户屏蔽的好友不会收到
+func pushUserState(user *User, status string, lastSeen int64) {
+	friends, err := dbUtils.ParseFriendList(user.FriendList())
+	if err != nil {
+		logger.Error("解析好友列表失败:", err)
+		return
+	}
+	payload := jsonprovider.SdandarlizeJSON_byte(configData.Commands.UserStateEvent, jsonprovider.UserStateEvent{
+		UserID:    user.UserId,
+		Status:    status,
+		LastSeen:  lastSeen,
+		TimeStamp: int(time.Now().UnixNano()),
+	})
+	for _, friend := range friends {
+		if getOnlineUser(friend.UserID) == nil || !canSeeOnlineStatus(user, friend.UserID) || isBlockedBy(friend.UserID, user.UserId) {
+			continue
+		}
+		_, err = sendMessageToUser(friend.UserID, payload)
+		if err != nil {
+			logger.Debug("在线状态推送失败", err)
+		}
+	}
+}
+
+// handleSetPresence 设置自己的在线状态，成功后同步到用户的所有在线会话并推送给好友
+func handleSetPresence(session *Session, request interface{}) {
+	req := request.(*jsonprovider.SetPresenceRequest)
+	user := session.User
+
+	res := jsonprovider.SetPresenceResponse{
+		Status:  req.Status,
+		Success: oneOf(req.Status, jsonprovider.PresenceOnline, jsonprovider.PresenceAway, jsonprovider.PresenceDoNotDisturb),
+	}
+	payload := jsonprovider.SdandarlizeJSON_byte(configData.Commands.SetPresence, res)
+	if !res.Success {
+		err := session.send(payload)
+		if err != nil {
+			logger.Error("在线状态设置结果回发失败:", err)
+		}
+		return
+	}
+
+	err := dbUtils.SavePresence(user.UserId, req.Status)
+	if err != nil {
+		logger.Error("保存在线状态失败:", err)
+		sendErrorResponse(session, configData.Commands.SetPresence, "设置在线状态失败")
+		return
+	}
+	user.setPresence(req.Status)
+	_, err = sendMessageToUser(user.UserId, payload)
+	if err != nil {
+		logger.Error("在线状态设置结果回发失败:", err)
+	}
+
+	unlock := lockPresence(user.UserId)
+	defer unlock()
+	if getOnlineUser(user.UserId) == user {
+		pushUserState(user, req.Status, 0)
+	}
+}
+
+// handleGetPresence 批量查询用户的在线状态，看不到在线状态的用户返回离线且不返回最后在线时间
+func handleGetPresence(session *Session, request interface{}) {
+	req := request.(*jsonprovider.GetPresenceRequest)
+	viewerID := session.User.UserId
+
+	if len(req.UserIDs) > maxPresenceQuery {
+		sendErrorResponse(session, configData.Commands.GetPresence, "一次最多查询"+strconv.Itoa(maxPresenceQuery)+"个用户")
+		return
+	}
+	lastSeen, err := dbUtils.GetLastSeen(req.UserIDs)
+	if err != nil {
+		logger.Error("获取最后在线时间失败:", err)
+		sendErrorResponse(session, configData.Commands.GetPresence, "查询在线状态失败")
+		return
+	}
+
+	presences := make([]jsonprovider.Presence, 0, len(req.UserIDs))
+	for _, userID := range req.UserIDs {
+		presence := jsonprovider.Presence{
+			UserID: userID,
+			Status: jsonprovider.PresenceOffline,
+		}
+		if canSeePresence(userID, viewerID) {
+			if user := getOnlineUser(userID); user != nil {
+				presence.Status = user.Presence()
+			} else {
+				presence.LastSeen = lastSeen[userID]
+			}
+		}
+		presences = append(presences, presence)
+	}
+
+	res := jsonprovider.GetPresenceResponse{
+		Presences: presences,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetPresence, res))
+	if err != nil {
+		logger.Error("在线状态回发失败:", err)
+	}
+}
+
+// canSeePresence 判断viewerID是否可以看到用户的在线状态与最后在线时间，被用户屏蔽时不可见
+func canSeePresence(userID int, viewerID int) bool {
+	if userID == viewerID {
+		return true
+	}
+	if isBlockedBy(viewerID, userID) {
+		return false
+	}
+	if user := getOnlineUser(userID); user != nil {
+		return canSeeOnlineStatus(user, viewerID)
+	}
+	settings, err := loadUserSettings(userID)
+	if err != nil {
+		logger.Error("读取用户设置失败:", err)
+		return false
+	}
+	switch settings.OnlineStatusVisibility {
+	case jsonprovider.VisibleToNobody:
+		return false
+	case jsonprovider.VisibleToFriends:
+		friends, err := dbUtils.GetFriendList(userID)
+		if err != nil {
+			logger.Error("读取好友列表失败:", err)
+			return false
+		}
+		return friends.Contains(viewerID)
+	default:
+		return true
+	}
+}
@@ -0,0 +1,32 @@
+package websocketService
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLockPresence(t *testing.T) {
+	const userID = 1
+	var wg sync.WaitGroup
+	inside := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := lockPresence(userID)
+			inside++
+			if inside != 1 {
+				t.Error("同一用户的在线状态锁被同时持有")
+			}
+			inside--
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	presenceLocksLock.Lock()
+	defer presenceLocksLock.Unlock()
+	if len(presenceLocks) != 0 {
+		t.Errorf("释放后仍保留了 %d 个在线状态锁", len(presenceLocks))
+	}
+}
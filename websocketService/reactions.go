@@ -0,0 +1,124 @@
+package websocketService
+
+import (
+	"database/sql"
+	"dbUtils"
+	"errors"
+	jsonprovider "jsonProvider"
+	"logger"
+	"time"
+	"unicode/utf8"
+)
+
+// maxReactionEmojiLength 表情回应的最大字符数，与 messagereactions.emoji 字段长度一致
+const maxReactionEmojiLength = 32
+
+var errInvalidReaction = errors.New("无效的表情")
+
+func handleAddReaction(session *Session, request interface{}) {
+	changeReaction(session, configData.Commands.AddReaction, request.(*jsonprovider.ReactionRequest), jsonprovider.ReactionEventAdd)
+}
+
+func handleRemoveReaction(session *Session, request interface{}) {
+	changeReaction(session, configData.Commands.RemoveReaction, request.(*jsonprovider.ReactionRequest), jsonprovider.ReactionEventRemove)
+}
+
+// changeReaction 添加或删除表情回应，回应发生变化时向会话的在线参与者推送 reactionEvent
+func changeReaction(session *Session, command string, req *jsonprovider.ReactionRequest, event string) {
+	userID := session.User.UserId
+
+	var err error
+	if req.Emoji == "" || utf8.RuneCountInString(req.Emoji) > maxReactionEmojiLength {
+		err = errInvalidReaction
+	}
+	var message *jsonprovider.Message
+	if err == nil {
+		message, err = dbUtils.GetMessage(req.MessageID)
+	}
+	var participants []int
+	if err == nil {
+		participants, err = reactionParticipants(message, userID)
+	}
+	changed := false
+	if err == nil {
+		if event == jsonprovider.ReactionEventAdd {
+			changed, err = dbUtils.AddReaction(message.MessageID, userID, req.Emoji)
+		} else {
+			changed, err = dbUtils.RemoveReaction(message.MessageID, userID, req.Emoji)
+		}
+	}
+
+	res := jsonprovider.ReactionResponse{
+		MessageID: req.MessageID,
+		Emoji:     req.Emoji,
+		Success:   err == nil,
+	}
+	if err != nil {
+		res.Message = messageErrorMessage(err)
+	}
+	sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(command, res))
+	if sendErr != nil {
+		logger.Error("表情回应结果回发失败:", sendErr)
+	}
+	if err != nil || !changed {
+		return
+	}
+
+	count, err := dbUtils.CountReaction(message.MessageID, req.Emoji)
+	if err != nil {
+		logger.Error("统计表情回应失败:", err)
+		return
+	}
+	pushMessageEvent(participants, configData.Commands.ReactionEvent, jsonprovider.ReactionEvent{
+		MessageID:  message.MessageID,
+		ReceiverID: message.ReceiverID,
+		GroupID:    message.GroupID,
+		Event:      event,
+		UserID:     userID,
+		Emoji:      req.Emoji,
+		Count:      count,
+		TimeStamp:  int(time.Now().UnixNano()),
+	})
+}
+
+// reactionParticipants 校验用户可以回应该消息，返回需要收到回应事件的用户：私聊的双方或当前所有群成员
+// 私聊中任一方屏蔽了对方时不能回应；群成员看不到的入群前的消息按消息不存在处理，避免探测消息ID
+func reactionParticipants(message *jsonprovider.Message, userID int) ([]int, error) {
+	if message.MessageType != UserMessage {
+		return nil, errNoMessagePermission
+	}
+	var participants []int
+	if message.GroupID == 0 {
+		if message.SenderID != userID && message.ReceiverID != userID {
+			return nil, errNoMessagePermission
+		}
+		peerID := message.SenderID
+		if peerID == userID {
+			peerID = message.ReceiverID
+		}
+		if isBlockedBy(userID, peerID) || isBlockedBy(peerID, userID) {
+			return nil, errNoMessagePermission
+		}
+		participants = []int{message.SenderID, message.ReceiverID}
+	} else {
+		membership, err := dbUtils.GetGroupMembership(message.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		if !membership.IsMember(userID) {
+			return nil, errNoMessagePermission
+		}
+		visible, err := canViewGroupHistory(message, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, sql.ErrNoRows
+		}
+		participants = membership.Members
+	}
+	if message.Recalled {
+		return nil, errMessageRecalled
+	}
+	return participants, nil
+}
@@ -0,0 +1,90 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+	"logger"
+	"time"
+)
+
+// handleMarkRead 将会话中到指定消息为止的消息标记为已读，群聊中的@消息同时标记为已读
+// 有消息新标记为已读时，只向这些消息的发送者推送已读回执；私聊中已屏蔽对方时不推送，群聊中发送者已退群时不推送
+func handleMarkRead(session *Session, request interface{}) {
+	req := request.(*jsonprovider.MarkReadRequest)
+	userID := session.User.UserId
+
+	var err error
+	var membership *dbUtils.GroupMembership
+	if req.GroupID != 0 {
+		membership, err = dbUtils.GetGroupMembership(int(req.GroupID))
+		if err == nil && !membership.IsMember(userID) {
+			err = errNotGroupMember
+		}
+	}
+	var senders []int
+	lastReadID := 0
+	if err == nil {
+		senders, lastReadID, err = dbUtils.MarkConversationRead(userID, req.UserID, int(req.GroupID), req.MessageID)
+	}
+	if err == nil && req.GroupID != 0 {
+		err = dbUtils.MarkMentionsRead(userID, int(req.GroupID), req.MessageID)
+	}
+	if err != nil {
+		logger.Error("标记消息已读失败:", err)
+	}
+
+	res := jsonprovider.MarkReadResponse{
+		UserID:    req.UserID,
+		GroupID:   req.GroupID,
+		MessageID: req.MessageID,
+		Success:   err == nil,
+	}
+	payload := jsonprovider.SdandarlizeJSON_byte(configData.Commands.MarkRead, res)
+	var sendErr error
+	if res.Success {
+		_, sendErr = sendMessageToUser(userID, payload)
+	} else {
+		sendErr = session.send(payload)
+	}
+	if sendErr != nil {
+		logger.Error("标记已读结果回发失败:", sendErr)
+	}
+	if err != nil || lastReadID == 0 {
+		return
+	}
+
+	receivers := make([]int, 0, len(senders))
+	for _, senderID := range removeUser(senders, userID) {
+		if membership != nil && !membership.IsMember(senderID) {
+			continue
+		}
+		if membership == nil && isBlockedBy(senderID, userID) {
+			// 已屏蔽对方时不向对方发送已读回执
+			continue
+		}
+		receivers = append(receivers, senderID)
+	}
+	pushMessageEvent(receivers, configData.Commands.ReadReceiptEvent, jsonprovider.ReadReceiptEvent{
+		UserID:    userID,
+		GroupID:   req.GroupID,
+		MessageID: lastReadID,
+		TimeStamp: int(time.Now().UnixNano()),
+	})
+}
+
+// handleGetConversations 获取用户的所有会话及其未读数和最后一条消息
+func handleGetConversations(session *Session, _ interface{}) {
+	conversations, err := dbUtils.GetConversations(session.User.UserId, UserMessage)
+	if err != nil {
+		logger.Error("获取会话列表失败:", err)
+		sendErrorResponse(session, configData.Commands.GetConversations, "获取会话列表失败")
+		return
+	}
+	res := jsonprovider.GetConversationsResponse{
+		Conversations: conversations,
+	}
+	err = session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.GetConversations, res))
+	if err != nil {
+		logger.Error("会话列表回发失败:", err)
+	}
+}
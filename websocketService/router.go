@@ -0,0 +1,88 @@
+package websocketService
+
+import (
+	jsonprovider "jsonProvider"
+	"logger"
+	"sync"
+)
+
+// CommandHandler 命令处理函数，session 为发送命令的会话，request 为 Command.NewRequest 创建并解析完成的请求结构体
+type CommandHandler func(session *Session, request interface{})
+
+// Command 一条已注册的WebSocket命令
+type Command struct {
+	Name       string
+	Permission uint               // 执行命令所需的最低权限，取值为 config.Permission*
+	NewRequest func() interface{} // 返回请求结构体指针，为nil时不解析请求
+	Handler    CommandHandler
+}
+
+var (
+	commands     = make(map[string]*Command) // 保存命令名与命令的映射关系
+	commandsLock sync.RWMutex
+)
+
+// RegisterCommand 注册WebSocket命令，命令名相同时覆盖已注册的命令
+// 其他包可以在 LoadConfig 之后调用此函数扩展命令
+func RegisterCommand(name string, permission uint, newRequest func() interface{}, handler CommandHandler) {
+	if name == "" || handler == nil {
+		logger.Error("注册命令失败，命令名或处理函数为空:", name)
+		return
+	}
+	commandsLock.Lock()
+	defer commandsLock.Unlock()
+	if _, exists := commands[name]; exists {
+		logger.Warn("命令", name, "已被注册，将被覆盖")
+	}
+	commands[name] = &Command{
+		Name:       name,
+		Permission: permission,
+		NewRequest: newRequest,
+		Handler:    handler,
+	}
+}
+
+// UnregisterCommand 注销WebSocket命令
+func UnregisterCommand(name string) {
+	commandsLock.Lock()
+	defer commandsLock.Unlock()
+	delete(commands, name)
+}
+
+// dispatchCommand 根据命令名查找处理函数，检查权限并解析请求后调用
+func dispatchCommand(session *Session, name string, message []byte) {
+	user := session.User
+	commandsLock.RLock()
+	command, ok := commands[name]
+	commandsLock.RUnlock()
+
+	if !ok {
+		logger.Warn("用户", user.UserId, "发送了未知的命令:", name)
+		sendErrorResponse(session, name, "未知的命令")
+		return
+	}
+
+	if user.UserPermission < command.Permission {
+		logger.Warn("用户", user.UserId, "权限不足，无法执行命令:", name)
+		sendErrorResponse(session, name, "权限不足")
+		return
+	}
+
+	var request interface{}
+	if command.NewRequest != nil {
+		request = command.NewRequest()
+		jsonprovider.ParseJSON(message, request)
+	}
+	command.Handler(session, request)
+}
+
+func sendErrorResponse(session *Session, command string, message string) {
+	res := jsonprovider.ErrorResponse{
+		State:   false,
+		Message: message,
+	}
+	err := session.send(jsonprovider.SdandarlizeJSON_byte(command, res))
+	if err != nil {
+		logger.Error("错误响应回发失败:", err)
+	}
+}
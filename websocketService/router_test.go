@@ -0,0 +1,88 @@
+package websocketService
+
+import (
+	"config"
+	"encoding/json"
+	jsonprovider "jsonProvider"
+	"testing"
+)
+
+type testCommandRequest struct {
+	Value string `json:"value"`
+}
+
+// readErrorResponse 读取会话队列中的下一条错误响应
+func readErrorResponse(t *testing.T, session *Session) (string, jsonprovider.ErrorResponse) {
+	t.Helper()
+	if session.queue.depth() == 0 {
+		t.Fatal("会话没有收到响应")
+	}
+	var pack struct {
+		Command string                     `json:"command"`
+		Content jsonprovider.ErrorResponse `json:"content"`
+	}
+	err := json.Unmarshal(<-session.queue.messages, &pack)
+	if err != nil {
+		t.Fatal("解析响应失败:", err)
+	}
+	return pack.Command, pack.Content
+}
+
+func TestDispatchCommand(t *testing.T) {
+	useConfig(t, func(conf *config.Config) {
+		conf.WebSocketSendQueueSize = 4
+	})
+	const name = "testOperatorCommand"
+	var received *testCommandRequest
+	RegisterCommand(name, config.PermissionOperator, func() interface{} { return new(testCommandRequest) }, func(session *Session, request interface{}) {
+		received = request.(*testCommandRequest)
+	})
+	t.Cleanup(func() {
+		UnregisterCommand(name)
+	})
+	message := []byte(`{"command":"testOperatorCommand","value":"hello"}`)
+
+	t.Run("未知的命令", func(t *testing.T) {
+		session := newTestSession(t, &User{User: jsonprovider.User{UserId: 1, UserPermission: config.PermissionRoot}})
+		dispatchCommand(session, "testUnknownCommand", message)
+		command, res := readErrorResponse(t, session)
+		if command != "testUnknownCommand" || res.State || res.Message != "未知的命令" {
+			t.Errorf("响应为 %s %+v", command, res)
+		}
+	})
+
+	t.Run("权限不足", func(t *testing.T) {
+		received = nil
+		session := newTestSession(t, &User{User: jsonprovider.User{UserId: 1, UserPermission: config.PermissionOrdinaryUser}})
+		dispatchCommand(session, name, message)
+		command, res := readErrorResponse(t, session)
+		if command != name || res.State || res.Message != "权限不足" {
+			t.Errorf("响应为 %s %+v", command, res)
+		}
+		if received != nil {
+			t.Error("权限不足时仍调用了处理函数")
+		}
+	})
+
+	t.Run("解析请求并调用处理函数", func(t *testing.T) {
+		received = nil
+		session := newTestSession(t, &User{User: jsonprovider.User{UserId: 1, UserPermission: config.PermissionOperator}})
+		dispatchCommand(session, name, message)
+		if received == nil || received.Value != "hello" {
+			t.Fatalf("处理函数收到的请求为 %+v", received)
+		}
+		if session.queue.depth() != 0 {
+			t.Error("命令执行成功时不应回发错误响应")
+		}
+	})
+
+	t.Run("注销后为未知命令", func(t *testing.T) {
+		UnregisterCommand(name)
+		received = nil
+		session := newTestSession(t, &User{User: jsonprovider.User{UserId: 1, UserPermission: config.PermissionRoot}})
+		dispatchCommand(session, name, message)
+		if _, res := readErrorResponse(t, session); res.Message != "未知的命令" || received != nil {
+			t.Errorf("注销的命令仍被执行: %+v", res)
+		}
+	})
+}
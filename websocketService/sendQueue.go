@@ -0,0 +1,167 @@
+package websocketService
+
+import (
+	"errors"
+	"logger"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 发送队列溢出策略，对应配置项 webSocketSendQueueOverflowPolicy
+const (
+	OverflowDropOldest = "dropOldest" // 丢弃队列中最早的消息
+	OverflowDisconnect = "disconnect" // 断开消费过慢的连接
+)
+
+const writeWait = 10 * time.Second // 单条消息的写超时
+
+var errQueueClosed = errors.New("发送队列已关闭")
+
+var (
+	droppedMessages      atomic.Uint64 // 因队列溢出被丢弃的消息总数
+	disconnectedConsumer atomic.Uint64 // 因队列溢出被断开的连接总数
+)
+
+// sendQueue 每个连接独占的发送队列，所有写操作都由 writeLoop 所在的协程完成
+type sendQueue struct {
+	conn       *websocket.Conn
+	messages   chan []byte
+	done       chan struct{}
+	closeOnce  sync.Once
+	pushLock   sync.Mutex    // 保证丢弃旧消息与写入新消息是原子的
+	pingPeriod time.Duration // 为0时不发送ping帧
+}
+
+// newSendQueue 创建发送队列，客户端未使用应用层心跳包时由写协程定时发送ping帧
+func newSendQueue(conn *websocket.Conn, artificialHeartbeat bool) *sendQueue {
+	size := configData.WebSocketSendQueueSize
+	if size <= 0 {
+		size = 1
+	}
+	var pingPeriod time.Duration
+	if !artificialHeartbeat {
+		// 每个读超时周期内发送两次ping，留出半个周期等待pong，避免网络抖动导致正常连接被断开
+		pingPeriod = time.Duration(configData.WebSocketHeartbeatTimeoutSeconds) * time.Second / 2
+	}
+	return &sendQueue{
+		conn:       conn,
+		messages:   make(chan []byte, size),
+		done:       make(chan struct{}),
+		pingPeriod: pingPeriod,
+	}
+}
+
+// push 将消息放入队列，不会阻塞调用者
+func (q *sendQueue) push(message []byte) error {
+	q.pushLock.Lock()
+	defer q.pushLock.Unlock()
+	for {
+		select {
+		case <-q.done:
+			return errQueueClosed
+		default:
+		}
+
+		select {
+		case q.messages <- message:
+			return nil
+		default:
+		}
+
+		// 队列已满
+		if configData.WebSocketSendQueueOverflowPolicy == OverflowDisconnect {
+			disconnectedConsumer.Add(1)
+			logger.Warn("发送队列已满，断开消费过慢的连接:", q.conn.RemoteAddr())
+			q.close()
+			return errQueueClosed
+		}
+		select {
+		case <-q.messages:
+			droppedMessages.Add(1)
+		default:
+		}
+	}
+}
+
+// depth 返回队列中等待发送的消息数
+func (q *sendQueue) depth() int {
+	return len(q.messages)
+}
+
+// close 停止写协程并关闭底层连接，可重复调用
+func (q *sendQueue) close() {
+	q.closeOnce.Do(func() {
+		close(q.done)
+		err := q.conn.Close()
+		if err != nil {
+			logger.Debug("关闭连接失败:", err)
+		}
+	})
+}
+
+// writeLoop 持续将队列中的消息写入连接并定时发送ping帧，直到队列关闭或写入失败
+func (q *sendQueue) writeLoop() {
+	defer q.close()
+	var ping <-chan time.Time
+	if q.pingPeriod > 0 {
+		ticker := time.NewTicker(q.pingPeriod)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ping:
+			err := q.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			if err != nil {
+				logger.Debug("ping帧发送失败:", err)
+				return
+			}
+		case message := <-q.messages:
+			err := q.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err != nil {
+				logger.Debug("设置写超时失败:", err)
+				return
+			}
+			err = q.conn.WriteMessage(websocket.TextMessage, message)
+			if err != nil {
+				logger.Error("消息发送失败:", err)
+				return
+			}
+		}
+	}
+}
+
+// QueueStats 发送队列的运行指标
+type QueueStats struct {
+	Connections          int
+	TotalDepth           int
+	MaxDepth             int
+	DroppedMessages      uint64
+	DisconnectedConsumer uint64
+}
+
+// GetQueueStats 统计所有在线连接的发送队列
+func GetQueueStats() QueueStats {
+	stats := QueueStats{
+		DroppedMessages:      droppedMessages.Load(),
+		DisconnectedConsumer: disconnectedConsumer.Load(),
+	}
+	ClientsLock.Lock()
+	defer ClientsLock.Unlock()
+	for _, client := range Clients {
+		for _, session := range client.Sessions {
+			depth := session.queue.depth()
+			stats.Connections++
+			stats.TotalDepth += depth
+			if depth > stats.MaxDepth {
+				stats.MaxDepth = depth
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,116 @@
+package websocketService
+
+import (
+	"config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// useConfig 在测试期间修改 configData，测试结束后恢复
+func useConfig(t *testing.T, modify func(conf *config.Config)) {
+	t.Helper()
+	saved := configData
+	modify(&configData)
+	t.Cleanup(func() {
+		configData = saved
+	})
+}
+
+// newTestConn 建立一条本地WebSocket连接并返回服务端一侧的连接
+func newTestConn(t *testing.T) *websocket.Conn {
+	t.Helper()
+	upgraded := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error("升级连接失败:", err)
+			return
+		}
+		upgraded <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal("连接测试服务器失败:", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	conn := <-upgraded
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+// newTestSession 创建不启动写协程的会话，发送的消息留在队列中供测试读取
+func newTestSession(t *testing.T, user *User) *Session {
+	t.Helper()
+	return &Session{
+		DeviceID: "test",
+		User:     user,
+		queue:    newSendQueue(newTestConn(t), true),
+	}
+}
+
+func TestSendQueueDropOldest(t *testing.T) {
+	useConfig(t, func(conf *config.Config) {
+		conf.WebSocketSendQueueSize = 2
+		conf.WebSocketSendQueueOverflowPolicy = OverflowDropOldest
+	})
+	queue := newSendQueue(newTestConn(t), true)
+	dropped := droppedMessages.Load()
+
+	for _, message := range []string{"a", "b", "c"} {
+		err := queue.push([]byte(message))
+		if err != nil {
+			t.Fatalf("push(%q) 返回错误: %v", message, err)
+		}
+	}
+
+	if got := droppedMessages.Load() - dropped; got != 1 {
+		t.Errorf("丢弃的消息数为 %d，期望 1", got)
+	}
+	if queue.depth() != 2 {
+		t.Fatalf("队列深度为 %d，期望 2", queue.depth())
+	}
+	for _, want := range []string{"b", "c"} {
+		if got := string(<-queue.messages); got != want {
+			t.Errorf("队列中的消息为 %q，期望 %q", got, want)
+		}
+	}
+}
+
+func TestSendQueueDisconnect(t *testing.T) {
+	useConfig(t, func(conf *config.Config) {
+		conf.WebSocketSendQueueSize = 1
+		conf.WebSocketSendQueueOverflowPolicy = OverflowDisconnect
+	})
+	queue := newSendQueue(newTestConn(t), true)
+	disconnected := disconnectedConsumer.Load()
+
+	err := queue.push([]byte("a"))
+	if err != nil {
+		t.Fatal("第一条消息入队失败:", err)
+	}
+	err = queue.push([]byte("b"))
+	if err != errQueueClosed {
+		t.Fatalf("队列已满时返回 %v，期望 errQueueClosed", err)
+	}
+	select {
+	case <-queue.done:
+	default:
+		t.Error("队列已满时没有关闭连接")
+	}
+	if got := disconnectedConsumer.Load() - disconnected; got != 1 {
+		t.Errorf("断开的连接数为 %d，期望 1", got)
+	}
+	if err = queue.push([]byte("c")); err != errQueueClosed {
+		t.Errorf("关闭后入队返回 %v，期望 errQueueClosed", err)
+	}
+}
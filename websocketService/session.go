@@ -0,0 +1,107 @@
+package websocketService
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Session 用户在一台设备上的连接，同一用户可以同时持有多个会话
+type Session struct {
+	DeviceID            string
+	Platform            string
+	ConnectTime         time.Time
+	Conn                *websocket.Conn
+	User                *User      // 会话所属的用户，同一用户的所有会话共享
+	queue               *sendQueue // 连接独占的发送队列
+	artificialHeartbeat bool       // 客户端是否使用应用层心跳包代替ping/pong
+}
+
+func newSession(user *User, conn *websocket.Conn, deviceID string, platform string, artificialHeartbeat bool) *Session {
+	if deviceID == "" {
+		// 客户端未提供设备ID时为本次连接生成一个，保证不会顶掉其他设备
+		deviceID = "anonymous-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return &Session{
+		DeviceID:            deviceID,
+		Platform:            platform,
+		ConnectTime:         time.Now(),
+		Conn:                conn,
+		User:                user,
+		queue:               newSendQueue(conn, artificialHeartbeat),
+		artificialHeartbeat: artificialHeartbeat,
+	}
+}
+
+// Send 将消息放入本会话的发送队列，供其他包注册的命令回发响应
+func (session *Session) Send(message []byte) error {
+	return session.send(message)
+}
+
+func (session *Session) send(message []byte) error {
+	return session.queue.push(message)
+}
+
+// Close 关闭会话连接，读取循环随之退出并清理映射关系
+func (session *Session) Close() {
+	session.queue.close()
+}
+
+// QueueDepth 返回该会话发送队列中等待发送的消息数
+func (session *Session) QueueDepth() int {
+	return session.queue.depth()
+}
+
+// attachSession 登录成功后保存会话，同一设备重复登录时返回被替换的旧会话
+// connected 为true表示这是用户的第一个会话，用户由离线变为在线
+func attachSession(session *Session) (replaced *Session, connected bool) {
+	ClientsLock.Lock()
+	defer ClientsLock.Unlock()
+	user, ok := Clients[session.User.UserId]
+	if !ok {
+		user = session.User
+		Clients[user.UserId] = user
+	}
+	session.User = user
+	replaced = user.Sessions[session.DeviceID]
+	user.Sessions[session.DeviceID] = session
+	return replaced, !ok
+}
+
+// detachSession 删除会话，用户的最后一个会话断开时从 Clients 中删除该用户并返回true
+func detachSession(session *Session) bool {
+	ClientsLock.Lock()
+	defer ClientsLock.Unlock()
+	user := session.User
+	if user.Sessions[session.DeviceID] == session {
+		delete(user.Sessions, session.DeviceID)
+	}
+	if len(user.Sessions) == 0 && Clients[user.UserId] == user {
+		delete(Clients, user.UserId)
+		return true
+	}
+	return false
+}
+
+// GetSessions 返回用户当前所有在线会话
+func GetSessions(userID int) []*Session {
+	ClientsLock.Lock()
+	defer ClientsLock.Unlock()
+	user, ok := Clients[userID]
+	if !ok {
+		return nil
+	}
+	sessions := make([]*Session, 0, len(user.Sessions))
+	for _, session := range user.Sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// getOnlineUser 返回在线用户，用户不在线时返回nil
+func getOnlineUser(userID int) *User {
+	ClientsLock.Lock()
+	defer ClientsLock.Unlock()
+	return Clients[userID]
+}
@@ -0,0 +1,125 @@
+package websocketService
+
+import (
+	"dbUtils"
+	jsonprovider "jsonProvider"
+	"logger"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	signalBurst         = 3           // 每个用户可以连续发送的信号数量，之后每 signalMinIntervalMillis 恢复一个
+	signalPruneInterval = time.Minute // 清理已恢复满的限流记录的间隔
+)
+
+// signalBucket 每个用户的信号令牌桶，不区分会话和信号类型
+type signalBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+var (
+	signalBuckets     = make(map[int]*signalBucket)
+	signalBucketsLock sync.Mutex
+)
+
+// handleSendSignal 转发正在输入等临时信号，信号不保存、不进入离线消息，接收方不在线时直接丢弃
+func handleSendSignal(session *Session, request interface{}) {
+	req := request.(*jsonprovider.SendSignalRequest)
+	sender := session.User
+
+	switch req.Type {
+	case jsonprovider.SignalTyping, jsonprovider.SignalStopped, jsonprovider.SignalRecording, jsonprovider.SignalViewing:
+	default:
+		sendErrorResponse(session, configData.Commands.SendSignal, "无效的信号类型")
+		return
+	}
+	if !allowSignal(sender.UserId, time.Now()) {
+		return
+	}
+
+	var receivers []int
+	if req.GroupID != 0 {
+		members, err := dbUtils.GetGroupMembers(int(req.GroupID))
+		if err != nil {
+			logger.Error("Failed to get group members:", err)
+			return
+		}
+		if !dbUtils.ContainsMember(members, sender.UserId) {
+			return
+		}
+		receivers = members
+	} else {
+		receivers = []int{req.UserID}
+	}
+
+	payload := jsonprovider.SdandarlizeJSON_byte(configData.Commands.SignalEvent, jsonprovider.SignalEvent{
+		SenderID:  sender.UserId,
+		GroupID:   req.GroupID,
+		Type:      req.Type,
+		TimeStamp: int(time.Now().UnixNano()),
+	})
+	for _, receiverID := range receivers {
+		if receiverID == sender.UserId || getOnlineUser(receiverID) == nil {
+			continue
+		}
+		// 接收方看不到发送者的在线状态、屏蔽了发送者或不接受发送者的私聊消息时不转发
+		if !canSeeOnlineStatus(sender, receiverID) {
+			continue
+		}
+		if req.GroupID != 0 && isBlockedBy(sender.UserId, receiverID) || req.GroupID == 0 && !canSendDirectMessage(sender.UserId, receiverID) {
+			continue
+		}
+		_, err := sendMessageToUser(receiverID, payload)
+		if err != nil {
+			logger.Debug("信号转发失败", err)
+		}
+	}
+}
+
+// allowSignal 按发送者限流，令牌桶中有剩余时允许发送并消耗一个令牌
+func allowSignal(senderID int, now time.Time) bool {
+	interval := time.Duration(configData.SignalMinIntervalMillis) * time.Millisecond
+	if interval <= 0 {
+		return true
+	}
+	signalBucketsLock.Lock()
+	defer signalBucketsLock.Unlock()
+
+	bucket, ok := signalBuckets[senderID]
+	if !ok {
+		bucket = &signalBucket{tokens: signalBurst, updated: now}
+		signalBuckets[senderID] = bucket
+	}
+	bucket.tokens = math.Min(signalBurst, bucket.tokens+float64(now.Sub(bucket.updated))/float64(interval))
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// StartSignalPruning 启动定时清理限流记录的协程，令牌已恢复满的记录与不存在时等价
+func StartSignalPruning() {
+	go func() {
+		ticker := time.NewTicker(signalPruneInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			pruneSignalBuckets(now)
+		}
+	}()
+}
+
+func pruneSignalBuckets(now time.Time) {
+	full := time.Duration(configData.SignalMinIntervalMillis) * time.Millisecond * signalBurst
+	signalBucketsLock.Lock()
+	defer signalBucketsLock.Unlock()
+	for senderID, bucket := range signalBuckets {
+		if now.Sub(bucket.updated) >= full {
+			delete(signalBuckets, senderID)
+		}
+	}
+}
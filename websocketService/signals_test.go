@@ -0,0 +1,89 @@
+package websocketService
+
+import (
+	"config"
+	"testing"
+	"time"
+)
+
+// resetSignalBuckets 清空限流记录，测试结束后再次清空
+func resetSignalBuckets(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		signalBucketsLock.Lock()
+		defer signalBucketsLock.Unlock()
+		signalBuckets = make(map[int]*signalBucket)
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestAllowSignal(t *testing.T) {
+	useConfig(t, func(conf *config.Config) {
+		conf.SignalMinIntervalMillis = 1000
+	})
+	resetSignalBuckets(t)
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < signalBurst; i++ {
+		if !allowSignal(1, now) {
+			t.Fatalf("第 %d 个信号被拒绝，应允许连续发送 %d 个", i+1, signalBurst)
+		}
+	}
+	if allowSignal(1, now) {
+		t.Error("令牌耗尽后仍允许发送")
+	}
+	if !allowSignal(2, now) {
+		t.Error("其他用户的信号受到了限流")
+	}
+	if allowSignal(1, now.Add(999*time.Millisecond)) {
+		t.Error("未到恢复间隔时允许发送")
+	}
+	if !allowSignal(1, now.Add(time.Second)) {
+		t.Error("经过一个间隔后没有恢复令牌")
+	}
+	// 长时间不发送时令牌最多恢复到 signalBurst 个
+	later := now.Add(time.Hour)
+	for i := 0; i < signalBurst; i++ {
+		if !allowSignal(1, later) {
+			t.Fatalf("恢复后第 %d 个信号被拒绝", i+1)
+		}
+	}
+	if allowSignal(1, later) {
+		t.Error("令牌恢复超过了 signalBurst")
+	}
+}
+
+func TestAllowSignalWithoutInterval(t *testing.T) {
+	useConfig(t, func(conf *config.Config) {
+		conf.SignalMinIntervalMillis = 0
+	})
+	resetSignalBuckets(t)
+	now := time.Now()
+	for i := 0; i < signalBurst*2; i++ {
+		if !allowSignal(1, now) {
+			t.Fatal("未配置间隔时不应限流")
+		}
+	}
+}
+
+func TestPruneSignalBuckets(t *testing.T) {
+	useConfig(t, func(conf *config.Config) {
+		conf.SignalMinIntervalMillis = 1000
+	})
+	resetSignalBuckets(t)
+	now := time.Unix(1700000000, 0)
+	allowSignal(1, now)
+	allowSignal(2, now.Add(2*time.Second))
+
+	pruneSignalBuckets(now.Add(signalBurst * time.Second))
+
+	signalBucketsLock.Lock()
+	defer signalBucketsLock.Unlock()
+	if _, ok := signalBuckets[1]; ok {
+		t.Error("令牌已恢复满的记录没有被清理")
+	}
+	if _, ok := signalBuckets[2]; !ok {
+		t.Error("令牌尚未恢复满的记录被清理")
+	}
+}
@@ -0,0 +1,221 @@
+package websocketService
+
+import (
+	"bytes"
+	"dbUtils"
+	"encoding/json"
+	"errors"
+	jsonprovider "jsonProvider"
+	"logger"
+)
+
+var errInvalidUserSettings = errors.New("无效的用户设置")
+
+// Settings 返回用户设置的副本
+func (user *User) Settings() jsonprovider.UserSettings {
+	user.settingsLock.RLock()
+	defer user.settingsLock.RUnlock()
+	return user.settings
+}
+
+func (user *User) setSettings(settings jsonprovider.UserSettings) {
+	user.settingsLock.Lock()
+	defer user.settingsLock.Unlock()
+	user.settings = settings
+}
+
+// loadUserSettings 获取用户设置，用户在线时使用内存中的设置
+func loadUserSettings(userID int) (jsonprovider.UserSettings, error) {
+	if user := getOnlineUser(userID); user != nil {
+		return user.Settings(), nil
+	}
+	settingsJSON, err := dbUtils.GetUserSettings(userID)
+	if err != nil {
+		return jsonprovider.UserSettings{}, err
+	}
+	return parseUserSettings(settingsJSON)
+}
+
+// parseUserSettings 解析数据库中保存的用户设置并校验，未设置的字段使用默认值
+func parseUserSettings(settingsJSON []byte) (jsonprovider.UserSettings, error) {
+	var settings jsonprovider.UserSettings
+	if len(settingsJSON) != 0 {
+		decoder := json.NewDecoder(bytes.NewReader(settingsJSON))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&settings)
+		if err != nil {
+			return settings, err
+		}
+	}
+	fillDefaultUserSettings(&settings)
+	return settings, validateUserSettings(settings)
+}
+
+// fillDefaultUserSettings 使用 config.json 中的默认设置补全为空的字段
+func fillDefaultUserSettings(settings *jsonprovider.UserSettings) {
+	defaults := configData.UserSettings.DefaultSettings
+	if settings.Notifications.DirectMessages == "" {
+		settings.Notifications.DirectMessages = defaults.Notifications.DirectMessages
+	}
+	if settings.Notifications.GroupMessages == "" {
+		settings.Notifications.GroupMessages = defaults.Notifications.GroupMessages
+	}
+	if settings.Notifications.FriendRequests == "" {
+		settings.Notifications.FriendRequests = defaults.Notifications.FriendRequests
+	}
+	if settings.OnlineStatusVisibility == "" {
+		settings.OnlineStatusVisibility = defaults.OnlineStatusVisibility
+	}
+	if settings.WhoCanAddMe == "" {
+		settings.WhoCanAddMe = defaults.WhoCanAddMe
+	}
+	if settings.DirectMessagePolicy == "" {
+		settings.DirectMessagePolicy = defaults.DirectMessagePolicy
+	}
+	if settings.Language == "" {
+		settings.Language = defaults.Language
+	}
+}
+
+func validateUserSettings(settings jsonprovider.UserSettings) error {
+	valid := oneOf(settings.Notifications.DirectMessages, jsonprovider.NotifyAll, jsonprovider.NotifyNone) &&
+		oneOf(settings.Notifications.GroupMessages, jsonprovider.NotifyAll, jsonprovider.NotifyMentions, jsonprovider.NotifyNone) &&
+		oneOf(settings.Notifications.FriendRequests, jsonprovider.NotifyAll, jsonprovider.NotifyNone) &&
+		oneOf(settings.OnlineStatusVisibility, jsonprovider.VisibleToEveryone, jsonprovider.VisibleToFriends, jsonprovider.VisibleToNobody) &&
+		oneOf(settings.WhoCanAddMe, jsonprovider.AddMeEveryone, jsonprovider.AddMeGroupMembers, jsonprovider.AddMeNobody) &&
+		oneOf(settings.DirectMessagePolicy, jsonprovider.DMPolicyDefault, jsonprovider.DMPolicyOpen, jsonprovider.DMPolicyFriends, jsonprovider.DMPolicyFriendsAndGroupMembers) &&
+		len(settings.Language) <= 16
+	if !valid {
+		return errInvalidUserSettings
+	}
+	return nil
+}
+
+func oneOf(value string, options ...string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
+
+// handleChangeSettings 以 JSON Merge Patch 方式修改用户设置，成功后同步到用户的所有在线会话
+func handleChangeSettings(session *Session, request interface{}) {
+	req := request.(*jsonprovider.ChangeSettingsRequest)
+	user := session.User
+
+	var res jsonprovider.ChangeSettingsResponse
+	user.updateLock.Lock()
+	settings, err := patchUserSettings(user.Settings(), req.Settings)
+	if err == nil {
+		err = dbUtils.SaveUserSettings(user.UserId, settings)
+		if err != nil {
+			logger.Error("保存用户设置失败:", err)
+		}
+	}
+	if err == nil {
+		user.setSettings(settings)
+	}
+	user.updateLock.Unlock()
+	if err != nil {
+		res.Message = "修改设置失败"
+		if errors.Is(err, errInvalidUserSettings) {
+			res.Message = err.Error()
+		}
+		sendErr := session.send(jsonprovider.SdandarlizeJSON_byte(configData.Commands.ChangeSettings, res))
+		if sendErr != nil {
+			logger.Error("修改设置结果回发失败:", sendErr)
+		}
+		return
+	}
+
+	res.Success = true
+	res.Settings = &settings
+	_, err = sendMessageToUser(user.UserId, jsonprovider.SdandarlizeJSON_byte(configData.Commands.ChangeSettings, res))
+	if err != nil {
+		logger.Error("修改设置结果回发失败:", err)
+	}
+}
+
+// patchUserSettings 将patch合并到当前设置并校验，包含未知字段或取值无效时返回 errInvalidUserSettings
+func patchUserSettings(current jsonprovider.UserSettings, patch json.RawMessage) (jsonprovider.UserSettings, error) {
+	if len(patch) == 0 {
+		return current, errInvalidUserSettings
+	}
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return current, err
+	}
+	merged, err := jsonprovider.MergePatch(currentJSON, patch)
+	if err != nil {
+		return current, errInvalidUserSettings
+	}
+	settings, err := parseUserSettings(merged)
+	if err != nil {
+		return current, errInvalidUserSettings
+	}
+	return settings, nil
+}
+
+// canSendDirectMessage 根据接收方的屏蔽列表和私聊消息策略判断能否向其发送私聊消息
+func canSendDirectMessage(senderID int, recipientID int) bool {
+	if isBlockedBy(senderID, recipientID) {
+		return false
+	}
+	settings, err := loadUserSettings(recipientID)
+	if err != nil {
+		logger.Error("读取用户设置失败:", err)
+		return false
+	}
+	policy := settings.DirectMessagePolicy
+	if policy == jsonprovider.DMPolicyDefault {
+		policy = configData.DirectMessagePolicy
+	}
+	switch policy {
+	case jsonprovider.DMPolicyFriends, jsonprovider.DMPolicyFriendsAndGroupMembers:
+		friends, err := dbUtils.GetFriendList(recipientID)
+		if err != nil {
+			logger.Error("读取好友列表失败:", err)
+			return false
+		}
+		if friends.Contains(senderID) {
+			return true
+		}
+		if policy == jsonprovider.DMPolicyFriends {
+			return false
+		}
+		shared, err := dbUtils.ShareGroup(senderID, recipientID)
+		if err != nil {
+			logger.Error("查询共同群聊失败:", err)
+			return false
+		}
+		return shared
+	default:
+		return true
+	}
+}
+
+// canSeeOnlineStatus 判断viewerID是否可以看到用户的在线状态
+func canSeeOnlineStatus(user *User, viewerID int) bool {
+	if user.UserId == viewerID {
+		return true
+	}
+	switch user.Settings().OnlineStatusVisibility {
+	case jsonprovider.VisibleToNobody:
+		return false
+	case jsonprovider.VisibleToFriends:
+		return isFriend(user.FriendList(), viewerID)
+	default:
+		return true
+	}
+}
+
+// isFriend 判断userID是否在好友列表中
+func isFriend(friendList json.RawMessage, userID int) bool {
+	friends, err := dbUtils.ParseFriendList(friendList)
+	if err != nil {
+		return false
+	}
+	return friends.Contains(userID)
+}